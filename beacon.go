@@ -0,0 +1,99 @@
+package towersofpau
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// scalarOrder is the order of the BLS12-381 subgroups, secrets are reduced modulo it
+var scalarOrder, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+// Beacon is a public random value announced before it is known, e.g. the hash of a future Ethereum block.
+// The final contribution derives its secrets from it, s.th. nobody could bias the result of the ceremony.
+type Beacon struct {
+	// Value is the hex encoded beacon value
+	Value string
+	// Rounds is the number of times the value is hashed before the secrets are derived, it delays
+	// the secrets s.th. they can not be tried out before the value has to be announced
+	Rounds int
+}
+
+// Identity identifies the beacon contribution, it is signed like the identity of a participant.
+func (b Beacon) Identity() string {
+	return fmt.Sprintf("beacon|%v|%d", b.Value, b.Rounds)
+}
+
+// Secrets derives the secrets for count transcripts from the beacon. The value is hashed Rounds times
+// with sha256, the secret of transcript i is sha256(seed || uint32(i)) reduced modulo the group order.
+func (b Beacon) Secrets(count int) ([][]byte, error) {
+	value, err := hexutil.Decode(b.Value)
+	if err != nil || len(value) == 0 {
+		return nil, errors.New("invalid beacon value")
+	}
+	if b.Rounds < 1 {
+		return nil, errors.New("beacon needs at least one round")
+	}
+	seed := sha256.Sum256(value)
+	for i := 1; i < b.Rounds; i++ {
+		seed = sha256.Sum256(seed[:])
+	}
+	secrets := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], uint32(i))
+		hash := sha256.Sum256(append(seed[:], index[:]...))
+		secret := new(big.Int).Mod(new(big.Int).SetBytes(hash[:]), scalarOrder)
+		if secret.Sign() == 0 {
+			return nil, errors.New("beacon derived a zero secret")
+		}
+		secrets = append(secrets, common.LeftPadBytes(secret.Bytes(), 32))
+	}
+	return secrets, nil
+}
+
+// ApplyBeacon adds the contribution derived from the beacon to the ceremony, every transcript is
+// signed with the identity of the beacon. Anyone can recompute it with VerifyBeacon.
+func ApplyBeacon(ceremony *Ceremony, beacon Beacon) error {
+	secrets, err := beacon.Secrets(len(ceremony.Transcripts))
+	if err != nil {
+		return err
+	}
+	for i, transcript := range ceremony.Transcripts {
+		if err := UpdatePowersOfTauFast(transcript, secrets[i]); err != nil {
+			return err
+		}
+		if err := UpdateWitness(transcript, secrets[i]); err != nil {
+			return err
+		}
+		if err := SignIdentity(transcript, secrets[i], beacon.Identity()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyBeacon verifies that newCeremony is the beacon contribution on top of prevCeremony by recomputing it.
+func VerifyBeacon(prevCeremony, newCeremony *Ceremony, beacon Beacon) error {
+	expected := prevCeremony.Copy()
+	if err := ApplyBeacon(expected, beacon); err != nil {
+		return err
+	}
+	want, err := expected.HashTreeRoot()
+	if err != nil {
+		return err
+	}
+	got, err := newCeremony.HashTreeRoot()
+	if err != nil {
+		return err
+	}
+	if want != got {
+		return errors.New("ceremony does not match the beacon contribution")
+	}
+	return nil
+}
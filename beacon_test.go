@@ -0,0 +1,63 @@
+package towersofpau
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestBeaconSecrets(t *testing.T) {
+	beacon := Beacon{Value: "0x0123456789abcdef", Rounds: 1000}
+	secrets, err := beacon.Secrets(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Computed independently with python hashlib
+	expected := []string{
+		"51121ff2d18af703117ef4779bbc829c2f8232f9dfa4ac3c9b23c870cfe77dd2",
+		"5b6b9e93350753a33620aa6ac6d141019d84e1b03004781ff761aafa0a6b3cd3",
+	}
+	for i, secret := range secrets {
+		if hex.EncodeToString(secret) != expected[i] {
+			t.Fatalf("secret %v: got %x, expected %v", i, secret, expected[i])
+		}
+	}
+
+	for _, invalid := range []Beacon{{Value: "", Rounds: 1}, {Value: "0xzz", Rounds: 1}, {Value: "0x01", Rounds: 0}} {
+		if _, err := invalid.Secrets(1); err == nil {
+			t.Fatalf("invalid beacon %+v accepted", invalid)
+		}
+	}
+}
+
+func TestBeacon(t *testing.T) {
+	ceremony := newTestCeremony()
+	if err := UpdateTranscript(ceremony, ""); err != nil {
+		t.Fatal(err)
+	}
+	beacon := Beacon{Value: "0x0123456789abcdef", Rounds: 10}
+	finalized := ceremony.Copy()
+	if err := ApplyBeacon(finalized, beacon); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(ceremony, finalized, beacon.Identity()); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBeacon(ceremony, finalized, beacon); err != nil {
+		t.Fatal(err)
+	}
+	if len(ceremony.Transcripts[0].Witness.PotPubkeys) != 2 {
+		t.Fatal("verification modified the previous ceremony")
+	}
+
+	other := Beacon{Value: beacon.Value, Rounds: 11}
+	if err := VerifyBeacon(ceremony, finalized, other); err == nil {
+		t.Fatal("contribution accepted for other rounds")
+	}
+	random := ceremony.Copy()
+	if err := UpdateTranscript(random, beacon.Identity()); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBeacon(ceremony, random, beacon); err == nil {
+		t.Fatal("random contribution accepted as beacon")
+	}
+}
@@ -0,0 +1,63 @@
+package towersofpau
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// benchmarkRounds is the number of scalar multiplications per thread and group
+const benchmarkRounds = 32
+
+// ContributionBenchmark is the measured speed of the machine of a participant.
+type ContributionBenchmark struct {
+	// G1Mult and G2Mult are the nanoseconds per scalar multiplication,
+	// measured with all threads working in parallel like UpdatePowersOfTauFast does
+	G1Mult int64
+	G2Mult int64
+}
+
+// RunContributionBenchmark measures how fast this machine can compute a contribution.
+func RunContributionBenchmark() ContributionBenchmark {
+	threads := runtime.NumCPU()
+	secret := new(blst.Scalar).Deserialize(common.LeftPadBytes(createRandom().Bytes(), 32))
+	return ContributionBenchmark{
+		G1Mult: measureParallel(threads, func() {
+			blst.P1Generator().Mult(secret)
+		}),
+		G2Mult: measureParallel(threads, func() {
+			blst.P2Generator().Mult(secret)
+		}),
+	}
+}
+
+// measureParallel returns the wall time per operation when running op on all threads.
+func measureParallel(threads int, op func()) int64 {
+	start := time.Now()
+	wg := new(sync.WaitGroup)
+	wg.Add(threads)
+	for i := 0; i < threads; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < benchmarkRounds; j++ {
+				op()
+			}
+		}()
+	}
+	wg.Wait()
+	return int64(time.Since(start)) / int64(threads*benchmarkRounds)
+}
+
+// EstimateContributionTime estimates how long a machine with the benchmark needs to contribute to the ceremony.
+func EstimateContributionTime(ceremony *Ceremony, benchmark ContributionBenchmark) time.Duration {
+	var g1, g2 int64
+	for _, t := range ceremony.Transcripts {
+		// The witness needs one multiplication in each group
+		g1 += int64(t.NumG1Powers) + 1
+		g2 += int64(t.NumG2Powers) + 1
+	}
+	return time.Duration(g1*benchmark.G1Mult + g2*benchmark.G2Mult)
+}
@@ -0,0 +1,23 @@
+package towersofpau
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateContributionTime(t *testing.T) {
+	ceremony := newTestCeremony()
+	benchmark := ContributionBenchmark{
+		G1Mult: int64(time.Millisecond),
+		G2Mult: 2 * int64(time.Millisecond),
+	}
+	// (16+1 + 8+1) G1 and (4+1 + 2+1) G2 multiplications
+	if d := EstimateContributionTime(ceremony, benchmark); d != 42*time.Millisecond {
+		t.Fatalf("wrong estimate: %v", d)
+	}
+
+	measured := RunContributionBenchmark()
+	if measured.G1Mult <= 0 || measured.G2Mult <= 0 {
+		t.Fatalf("invalid benchmark: %+v", measured)
+	}
+}
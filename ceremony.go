@@ -12,8 +12,12 @@ import (
 	blst "github.com/supranational/blst/bindings/go"
 )
 
-// UpdateTranscript adds our contribution to the ceremony
-func UpdateTranscript(ceremony *Ceremony) error {
+// blsSignatureDST is the domain separation tag of the min-sig proof-of-possession scheme
+var blsSignatureDST = []byte("BLS_SIG_BLS12381G1_XMD:SHA-256_SSWU_RO_POP_")
+
+// UpdateTranscript adds our contribution to the ceremony,
+// if identity is not empty we sign it with the secret of every transcript.
+func UpdateTranscript(ceremony *Ceremony, identity string) error {
 	for _, transcript := range ceremony.Transcripts {
 		rnd := createRandom()
 		secret := common.LeftPadBytes(rnd.Bytes(), 32)
@@ -23,13 +27,32 @@ func UpdateTranscript(ceremony *Ceremony) error {
 		if err := UpdateWitness(transcript, secret); err != nil {
 			return err
 		}
+		if identity != "" {
+			if err := SignIdentity(transcript, secret, identity); err != nil {
+				return err
+			}
+		}
 		// Clear secret
 		rand.Read(secret)
 	}
 	return nil
 }
 
-func VerifySubmission(prevCeremony, newCeremony *Ceremony) error {
+// VerifySubmission verifies that newCeremony is a valid contribution on top of prevCeremony,
+// if identity is not empty the contribution has to be signed by it.
+func VerifySubmission(prevCeremony, newCeremony *Ceremony, identity string) error {
+	if err := CheckSubmission(prevCeremony, newCeremony, identity); err != nil {
+		return err
+	}
+	if !VerifyPairing(newCeremony) {
+		return errors.New("pairing check failed")
+	}
+	return nil
+}
+
+// CheckSubmission runs all checks of VerifySubmission except for the expensive pairing check.
+// Coordinators can hand on a submission that passed these checks while the pairing check is still running.
+func CheckSubmission(prevCeremony, newCeremony *Ceremony, identity string) error {
 	if err := checkLength(prevCeremony, newCeremony); err != nil {
 		return err
 	}
@@ -42,8 +65,20 @@ func VerifySubmission(prevCeremony, newCeremony *Ceremony) error {
 		return errors.New("subgroup check failed")
 	}
 
-	if !WitnessContinuityCheck(prevCeremony, newCeremony) {
-		return errors.New("continuity check failed")
+	if err := WitnessContinuityCheck(prevCeremony, newCeremony); err != nil {
+		return fmt.Errorf("continuity check failed: %v", err)
+	}
+
+	if !TrivialSecretCheck(newCeremony) {
+		return errors.New("trivial secret check failed")
+	}
+
+	if !PowersChangedCheck(prevCeremony, newCeremony) {
+		return errors.New("powers changed check failed")
+	}
+
+	if identity != "" && !BLSSignatureCheck(newCeremony, identity) {
+		return errors.New("bls signature check failed")
 	}
 
 	/*
@@ -51,20 +86,28 @@ func VerifySubmission(prevCeremony, newCeremony *Ceremony) error {
 		if !PubkeyUniquenessCheck(newCeremony) {
 			return errors.New("pubkey uniqueness check failed")
 		}*/
-
-	if !VerifyPairing(newCeremony) {
-		return errors.New("pairing check failed")
-	}
 	return nil
 }
 
+// checkLength checks that next has the shape of prev with one more contribution to every transcript.
 func checkLength(prev, next *Ceremony) error {
+	if len(prev.Transcripts) != len(next.Transcripts) {
+		return errors.New("number of transcripts changed")
+	}
 	for i, t := range prev.Transcripts {
-		if len(t.Witness.PotPubkeys) >= len(next.Transcripts[i].Witness.PotPubkeys) {
-			errors.New("pot_pubkeys did not increase")
+		n := next.Transcripts[i]
+		if n == nil || n.Witness == nil {
+			return fmt.Errorf("transcript %v is missing", i)
+		}
+		if len(t.PowersOfTau.G1Powers) != len(n.PowersOfTau.G1Powers) ||
+			len(t.PowersOfTau.G2Powers) != len(n.PowersOfTau.G2Powers) {
+			return fmt.Errorf("number of powers of transcript %v changed", i)
 		}
-		if len(t.Witness.RunningProducts) >= len(next.Transcripts[i].Witness.RunningProducts) {
-			errors.New("running_products did not increase")
+		if len(t.Witness.PotPubkeys)+1 != len(n.Witness.PotPubkeys) {
+			return errors.New("pot_pubkeys did not grow by one")
+		}
+		if len(t.Witness.RunningProducts)+1 != len(n.Witness.RunningProducts) {
+			return errors.New("running_products did not grow by one")
 		}
 	}
 	return nil
@@ -135,10 +178,28 @@ func UpdateWitness(transcript *Transcript, secret []byte) error {
 	if newPk == nil {
 		return errors.New("invalid pk")
 	}
+	transcript.Witness.BlsSignatures = append(transcript.Witness.paddedSignatures(), nil)
 	transcript.Witness.PotPubkeys = append(transcript.Witness.PotPubkeys, *newPk)
 	return nil
 }
 
+// SignIdentity signs identity with our secret, proving that we know the secret behind our pot pubkey.
+func SignIdentity(transcript *Transcript, secret []byte, identity string) error {
+	sec := new(blst.SecretKey).Deserialize(secret)
+	if sec == nil {
+		return errors.New("invalid secret")
+	}
+	if len(transcript.Witness.BlsSignatures) == 0 {
+		return errors.New("no contribution to sign")
+	}
+	sig := new(blst.P1Affine).Sign(sec, []byte(identity), blsSignatureDST)
+	if sig == nil {
+		return errors.New("signing failed")
+	}
+	transcript.Witness.BlsSignatures[len(transcript.Witness.BlsSignatures)-1] = sig
+	return nil
+}
+
 func createRandom() *big.Int {
 	for i := 0; i < 1000000; i++ {
 		b := make([]byte, 32)
@@ -215,6 +276,68 @@ func NonZeroCheck(ceremony *Ceremony) bool {
 	return true
 }
 
+// BLSSignatureCheck verifies the signatures of the latest contribution over identity
+func BLSSignatureCheck(ceremony *Ceremony, identity string) bool {
+	for _, transcript := range ceremony.Transcripts {
+		witness := transcript.Witness
+		if len(witness.PotPubkeys) == 0 || len(witness.BlsSignatures) != len(witness.PotPubkeys) {
+			return false
+		}
+		sig := witness.BlsSignatures[len(witness.BlsSignatures)-1]
+		pk := &witness.PotPubkeys[len(witness.PotPubkeys)-1]
+		if sig == nil || !sig.Verify(true, pk, true, []byte(identity), blsSignatureDST) {
+			return false
+		}
+	}
+	return true
+}
+
+// TrivialSecretCheck checks that the latest contribution to every transcript did not use a secret of 0 or 1,
+// whose pot pubkey is the point at infinity or the generator.
+func TrivialSecretCheck(ceremony *Ceremony) bool {
+	generator := blst.P2Generator().ToAffine()
+	infinity := new(blst.P2Affine)
+	for _, transcript := range ceremony.Transcripts {
+		pubkeys := transcript.Witness.PotPubkeys
+		if len(pubkeys) == 0 {
+			return false
+		}
+		pk := &pubkeys[len(pubkeys)-1]
+		if pk.Equals(generator) || pk.Equals(infinity) {
+			return false
+		}
+	}
+	return true
+}
+
+// PowersChangedCheck checks that the powers of every transcript differ from the previous ones,
+// a submission that returns the previous powers did not add any randomness.
+func PowersChangedCheck(prevCeremony, newCeremony *Ceremony) bool {
+	for index, prev := range prevCeremony.Transcripts {
+		next := newCeremony.Transcripts[index]
+		if p1ArrayEquals(prev.PowersOfTau.G1Powers, next.PowersOfTau.G1Powers) &&
+			p2PowersEquals(prev.PowersOfTau.G2Powers, next.PowersOfTau.G2Powers) {
+			return false
+		}
+	}
+	return true
+}
+
+// PowersWitnessCheck checks that the first G1 power of every transcript equals the latest running product,
+// both are the generator times the product of all secrets.
+func PowersWitnessCheck(ceremony *Ceremony) bool {
+	for _, transcript := range ceremony.Transcripts {
+		products := transcript.Witness.RunningProducts
+		if len(products) == 0 || len(transcript.PowersOfTau.G1Powers) == 0 {
+			return false
+		}
+		if !transcript.PowersOfTau.G1Powers[0].Equals(products[len(products)-1]) {
+			return false
+		}
+	}
+	return true
+}
+
 func PubkeyUniquenessCheck(ceremony *Ceremony) bool {
 	keys := make(map[blst.P2Affine]struct{}, 0)
 	var numKeys int
@@ -227,21 +350,31 @@ func PubkeyUniquenessCheck(ceremony *Ceremony) bool {
 	return len(keys) == numKeys
 }
 
-func WitnessContinuityCheck(prevCeremony, newCeremony *Ceremony) bool {
+// WitnessContinuityCheck checks that the witness of every transcript of newCeremony extends
+// the one of prevCeremony by exactly one contribution.
+func WitnessContinuityCheck(prevCeremony, newCeremony *Ceremony) error {
+	if len(prevCeremony.Transcripts) != len(newCeremony.Transcripts) {
+		return errors.New("number of transcripts changed")
+	}
 	for index := range prevCeremony.Transcripts {
 		oldWitness := prevCeremony.Transcripts[index].Witness
 		newWitness := newCeremony.Transcripts[index].Witness
-		// TODO check that we do a correct check
+		if len(newWitness.RunningProducts) != len(oldWitness.RunningProducts)+1 ||
+			len(newWitness.PotPubkeys) != len(oldWitness.PotPubkeys)+1 {
+			return fmt.Errorf("witness of transcript %v does not add one contribution", index)
+		}
 		if !p1ArrayEquals(oldWitness.RunningProducts, newWitness.RunningProducts[:len(newWitness.RunningProducts)-1]) {
-			panic(fmt.Sprintf("%v %v %v", index, oldWitness.RunningProducts, newWitness.RunningProducts))
-			return false
+			return fmt.Errorf("running_products of transcript %v do not extend the previous ones", index)
 		}
 		if !p2ArrayEquals(oldWitness.PotPubkeys, newWitness.PotPubkeys[:len(newWitness.PotPubkeys)-1]) {
-			panic(fmt.Sprintf("%v %v %v", index, oldWitness.PotPubkeys, newWitness.PotPubkeys))
-			return false
+			return fmt.Errorf("pot_pubkeys of transcript %v do not extend the previous ones", index)
+		}
+		newSignatures := newWitness.paddedSignatures()
+		if !signatureArrayEquals(oldWitness.paddedSignatures(), newSignatures[:len(newSignatures)-1]) {
+			return fmt.Errorf("bls_signatures of transcript %v do not extend the previous ones", index)
 		}
 	}
-	return true
+	return nil
 }
 
 func p1ArrayEquals(p1, p2 []*blst.P1) bool {
@@ -268,6 +401,33 @@ func p2ArrayEquals(p1, p2 blst.P2Affines) bool {
 	return true
 }
 
+func p2PowersEquals(p1, p2 []*blst.P2) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for idx := range p1 {
+		if !p1[idx].Equals(p2[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+func signatureArrayEquals(s1, s2 []*blst.P1Affine) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for idx := range s1 {
+		if (s1[idx] == nil) != (s2[idx] == nil) {
+			return false
+		}
+		if s1[idx] != nil && !s1[idx].Equals(s2[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
 func VerifyPairing(ceremony *Ceremony) bool {
 	for _, t := range ceremony.Transcripts {
 		if !verifyPairing(t) {
@@ -282,7 +442,22 @@ func verifyPairing(t *Transcript) bool {
 		len(t.PowersOfTau.G1Powers) < 2 || len(t.PowersOfTau.G2Powers) < 2 {
 		return false
 	}
+	relations := make([]int, pairingRelations(t))
+	for i := range relations {
+		relations[i] = i
+	}
+	return checkRelations(t, relations)
+}
+
+// pairingRelations returns the number of pairing relations of the transcript: one between every
+// two adjacent G1 powers, G2 powers and running products.
+func pairingRelations(t *Transcript) int {
+	return len(t.PowersOfTau.G1Powers) - 1 + len(t.PowersOfTau.G2Powers) - 1 + len(t.Witness.RunningProducts) - 1
+}
 
+// checkRelations checks the pairing relations with the indices in parallel, relations are numbered
+// G1 powers first, then G2 powers and running products.
+func checkRelations(t *Transcript, relations []int) bool {
 	var (
 		g2_0 = t.PowersOfTau.G2Powers[0].ToAffine()
 		g2_1 = t.PowersOfTau.G2Powers[1].ToAffine()
@@ -290,50 +465,39 @@ func verifyPairing(t *Transcript) bool {
 		g1_0 = t.PowersOfTau.G1Powers[0].ToAffine()
 		g1_1 = t.PowersOfTau.G1Powers[1].ToAffine()
 
+		p2_g = blst.P2Generator().ToAffine()
+
+		g1Relations = len(t.PowersOfTau.G1Powers) - 1
+		g2Relations = len(t.PowersOfTau.G2Powers) - 1
+
 		failed int32
 		wg     = new(sync.WaitGroup)
 	)
 
-	wg.Add(len(t.PowersOfTau.G1Powers) - 1)
-	for i := 0; i < len(t.PowersOfTau.G1Powers)-1; i++ {
-		go func(i int) {
-			defer wg.Done()
-			pair1 := blst.Fp12MillerLoop(g2_1, t.PowersOfTau.G1Powers[i].ToAffine())
-			pair2 := blst.Fp12MillerLoop(g2_0, t.PowersOfTau.G1Powers[i+1].ToAffine())
-
-			if !blst.Fp12FinalVerify(pair1, pair2) {
-				atomic.AddInt32(&failed, 1)
-			}
-		}(i)
-	}
-	wg.Wait()
-
-	wg.Add(len(t.PowersOfTau.G2Powers) - 1)
-	for i := 0; i < len(t.PowersOfTau.G2Powers)-1; i++ {
-		go func(i int) {
+	wg.Add(len(relations))
+	for _, r := range relations {
+		go func(r int) {
 			defer wg.Done()
-			pair1 := blst.Fp12MillerLoop(t.PowersOfTau.G2Powers[i].ToAffine(), g1_1)
-			pair2 := blst.Fp12MillerLoop(t.PowersOfTau.G2Powers[i+1].ToAffine(), g1_0)
-
-			if !blst.Fp12FinalVerify(pair1, pair2) {
-				atomic.AddInt32(&failed, 1)
+			var pair1, pair2 *blst.Fp12
+			switch {
+			case r < g1Relations:
+				i := r
+				pair1 = blst.Fp12MillerLoop(g2_1, t.PowersOfTau.G1Powers[i].ToAffine())
+				pair2 = blst.Fp12MillerLoop(g2_0, t.PowersOfTau.G1Powers[i+1].ToAffine())
+			case r < g1Relations+g2Relations:
+				i := r - g1Relations
+				pair1 = blst.Fp12MillerLoop(t.PowersOfTau.G2Powers[i].ToAffine(), g1_1)
+				pair2 = blst.Fp12MillerLoop(t.PowersOfTau.G2Powers[i+1].ToAffine(), g1_0)
+			default:
+				i := r - g1Relations - g2Relations
+				pair1 = blst.Fp12MillerLoop(&t.Witness.PotPubkeys[i+1], t.Witness.RunningProducts[i].ToAffine())
+				pair2 = blst.Fp12MillerLoop(p2_g, t.Witness.RunningProducts[i+1].ToAffine())
 			}
-		}(i)
-	}
-	wg.Wait()
-
-	p2_g := blst.P2Generator().ToAffine()
-	wg.Add(len(t.Witness.RunningProducts) - 1)
-	for i := 0; i < len(t.Witness.RunningProducts)-1; i++ {
-		go func(i int) {
-			defer wg.Done()
-			pair1 := blst.Fp12MillerLoop(&t.Witness.PotPubkeys[i+1], t.Witness.RunningProducts[i].ToAffine())
-			pair2 := blst.Fp12MillerLoop(p2_g, t.Witness.RunningProducts[i+1].ToAffine())
 
 			if !blst.Fp12FinalVerify(pair1, pair2) {
 				atomic.AddInt32(&failed, 1)
 			}
-		}(i)
+		}(r)
 	}
 	wg.Wait()
 	return failed == 0
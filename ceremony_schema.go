@@ -3,6 +3,7 @@ package towersofpau
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 
 	"io"
 	"strings"
@@ -33,6 +34,8 @@ func (p *PowersOfTau) Copy() PowersOfTau {
 type Witness struct {
 	RunningProducts []*blst.P1
 	PotPubkeys      blst.P2Affines
+	// BlsSignatures over the identity of the contributor, nil if the contributor did not sign
+	BlsSignatures []*blst.P1Affine
 }
 
 func (w *Witness) Copy() *Witness {
@@ -40,12 +43,26 @@ func (w *Witness) Copy() *Witness {
 	for _, p := range w.RunningProducts {
 		products = append(products, &(*p))
 	}
+	pubkeys := make(blst.P2Affines, 0, len(w.PotPubkeys))
+	pubkeys = append(pubkeys, w.PotPubkeys...)
+	signatures := make([]*blst.P1Affine, 0, len(w.BlsSignatures))
+	signatures = append(signatures, w.BlsSignatures...)
 	return &Witness{
 		RunningProducts: products,
-		PotPubkeys:      w.PotPubkeys,
+		PotPubkeys:      pubkeys,
+		BlsSignatures:   signatures,
 	}
 }
 
+// paddedSignatures returns the signatures with a nil entry for every unsigned pot pubkey
+func (w *Witness) paddedSignatures() []*blst.P1Affine {
+	signatures := w.BlsSignatures
+	for len(signatures) < len(w.PotPubkeys) {
+		signatures = append(signatures, nil)
+	}
+	return signatures
+}
+
 type Transcript struct {
 	NumG1Powers int
 	NumG2Powers int
@@ -76,6 +93,37 @@ func (c *Ceremony) Copy() *Ceremony {
 	}
 }
 
+// TranscriptCeremony returns a ceremony consisting only of the transcript with the index,
+// pipelined coordinators hand out the transcripts of a ceremony individually.
+func (c *Ceremony) TranscriptCeremony(index int) *Ceremony {
+	return &Ceremony{
+		Transcripts: []*Transcript{c.Transcripts[index]},
+	}
+}
+
+// WithTranscript returns a ceremony with the transcript at index replaced, the other transcripts are shared with c.
+func (c *Ceremony) WithTranscript(index int, transcript *Transcript) *Ceremony {
+	transcripts := make([]*Transcript, len(c.Transcripts))
+	copy(transcripts, c.Transcripts)
+	transcripts[index] = transcript
+	return &Ceremony{
+		Transcripts: transcripts,
+	}
+}
+
+// LatestPotPubkeys returns the hex encoded pot pubkeys of the latest contribution to each transcript.
+func (c *Ceremony) LatestPotPubkeys() []string {
+	pubkeys := make([]string, 0, len(c.Transcripts))
+	for _, t := range c.Transcripts {
+		if len(t.Witness.PotPubkeys) == 0 {
+			pubkeys = append(pubkeys, "")
+			continue
+		}
+		pubkeys = append(pubkeys, "0x"+hex.EncodeToString(t.Witness.PotPubkeys[len(t.Witness.PotPubkeys)-1].Compress()))
+	}
+	return pubkeys
+}
+
 type JSONPowersOfTau struct {
 	G1Powers []string
 	G2Powers []string
@@ -84,6 +132,7 @@ type JSONPowersOfTau struct {
 type JSONWitness struct {
 	RunningProducts []string `json:"runningProducts"`
 	PotPubkeys      []string `json:"potPubkeys"`
+	BlsSignatures   []string `json:"blsSignatures,omitempty"`
 }
 
 type JSONTranscript struct {
@@ -183,6 +232,25 @@ func DeserializeJSONCeremony(jsonceremony JSONCeremony) (*Ceremony, error) {
 			transcript.Witness.PotPubkeys[i] = *affine
 		}
 
+		// Ceremonies without signatures omit the field, contributions without signature are empty
+		transcript.Witness.BlsSignatures = make([]*blst.P1Affine, len(jsontranscript.Witness.PotPubkeys))
+		if len(jsontranscript.Witness.BlsSignatures) > len(transcript.Witness.BlsSignatures) {
+			return nil, errors.New("more bls signatures than pot pubkeys")
+		}
+		for i, sig := range jsontranscript.Witness.BlsSignatures {
+			if sig == "" {
+				continue
+			}
+			b, err := hex.DecodeString(strings.TrimPrefix(sig, "0x"))
+			if err != nil {
+				return nil, err
+			}
+			transcript.Witness.BlsSignatures[i] = new(blst.P1Affine).Uncompress(b)
+			if transcript.Witness.BlsSignatures[i] == nil {
+				return nil, errors.New("invalid bls signature")
+			}
+		}
+
 		ceremony.Transcripts = append(ceremony.Transcripts, &transcript)
 	}
 	return &ceremony, nil
@@ -216,6 +284,7 @@ func SerializeJSONCeremony(ceremony *Ceremony) (JSONCeremony, error) {
 			Witness: JSONWitness{
 				RunningProducts: make([]string, len(transcript.Witness.RunningProducts)),
 				PotPubkeys:      make([]string, len(transcript.Witness.PotPubkeys)),
+				BlsSignatures:   make([]string, len(transcript.Witness.BlsSignatures)),
 			},
 		}
 
@@ -235,6 +304,17 @@ func SerializeJSONCeremony(ceremony *Ceremony) (JSONCeremony, error) {
 			jsontranscript.Witness.PotPubkeys[i] = "0x" + hex.EncodeToString(point.Compress())
 		}
 
+		var signed bool
+		for i, sig := range transcript.Witness.BlsSignatures {
+			if sig != nil {
+				jsontranscript.Witness.BlsSignatures[i] = "0x" + hex.EncodeToString(sig.Compress())
+				signed = true
+			}
+		}
+		if !signed {
+			jsontranscript.Witness.BlsSignatures = nil
+		}
+
 		jsonceremony.Transcripts = append(jsonceremony.Transcripts, jsontranscript)
 	}
 	return jsonceremony, nil
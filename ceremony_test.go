@@ -1,8 +1,11 @@
 package towersofpau
 
 import (
+	"bytes"
 	"os"
 	"testing"
+
+	blst "github.com/supranational/blst/bindings/go"
 )
 
 func TestCeremonyChecks(t *testing.T) {
@@ -46,11 +49,11 @@ func TestParticipation(t *testing.T) {
 	}
 
 	updatedCeremony := ceremony.Copy()
-	if err := UpdateTranscript(updatedCeremony); err != nil {
+	if err := UpdateTranscript(updatedCeremony, ""); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := VerifySubmission(ceremony, updatedCeremony); err != nil {
+	if err := VerifySubmission(ceremony, updatedCeremony, ""); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -80,7 +83,7 @@ func BenchmarkContribution(t *testing.B) {
 		t.Fatal(err)
 	}
 	t.ResetTimer()
-	if err := UpdateTranscript(ceremony); err != nil {
+	if err := UpdateTranscript(ceremony, ""); err != nil {
 		t.Fatal(err)
 	}
 	panic("asdf")
@@ -109,3 +112,218 @@ func BenchmarkPairing(b *testing.B) {
 	}
 	_ = pot
 }
+
+// newTestCeremony creates a ceremony in its initial state, where all powers are the generators
+func newTestCeremony() *Ceremony {
+	ceremony := new(Ceremony)
+	for _, size := range [][2]int{{16, 4}, {8, 2}} {
+		transcript := &Transcript{
+			NumG1Powers: size[0],
+			NumG2Powers: size[1],
+			Witness: &Witness{
+				RunningProducts: []*blst.P1{blst.P1Generator()},
+				PotPubkeys:      blst.P2Affines{*blst.P2Generator().ToAffine()},
+			},
+		}
+		for i := 0; i < size[0]; i++ {
+			transcript.PowersOfTau.G1Powers = append(transcript.PowersOfTau.G1Powers, blst.P1Generator())
+		}
+		for i := 0; i < size[1]; i++ {
+			transcript.PowersOfTau.G2Powers = append(transcript.PowersOfTau.G2Powers, blst.P2Generator())
+		}
+		ceremony.Transcripts = append(ceremony.Transcripts, transcript)
+	}
+	return ceremony
+}
+
+func TestIdentitySignatures(t *testing.T) {
+	ceremony := newTestCeremony()
+	updatedCeremony := ceremony.Copy()
+	if err := UpdateTranscript(updatedCeremony, "eth|0x0123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(ceremony, updatedCeremony, "eth|0x0123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(ceremony, updatedCeremony, "eth|0x4567"); err == nil {
+		t.Fatal("signature accepted for wrong identity")
+	}
+
+	// Signatures survive a serialization roundtrip
+	buf := new(bytes.Buffer)
+	if err := Serialize(buf, updatedCeremony); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Deserialize(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !BLSSignatureCheck(decoded, "eth|0x0123") {
+		t.Fatal("signature check failed after roundtrip")
+	}
+
+	// An unsigned contribution is only accepted without identity
+	unsigned := decoded.Copy()
+	if err := UpdateTranscript(unsigned, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(decoded, unsigned, "eth|0x0123"); err == nil {
+		t.Fatal("unsigned contribution accepted")
+	}
+	if err := VerifySubmission(decoded, unsigned, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTranscriptCeremony(t *testing.T) {
+	ceremony := newTestCeremony()
+	// Contribute to the second transcript only
+	prev := ceremony.TranscriptCeremony(1)
+	next := prev.Copy()
+	if err := UpdateTranscript(next, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(prev, next, ""); err != nil {
+		t.Fatal(err)
+	}
+	merged := ceremony.WithTranscript(1, next.Transcripts[0])
+	if merged.Transcripts[0] != ceremony.Transcripts[0] || merged.Transcripts[1] != next.Transcripts[0] {
+		t.Fatal("transcripts not merged")
+	}
+	if len(ceremony.Transcripts[1].Witness.PotPubkeys) != 1 {
+		t.Fatal("original ceremony modified")
+	}
+	if !VerifyPairing(merged) {
+		t.Fatal("merged ceremony invalid")
+	}
+}
+
+func TestCheckSubmission(t *testing.T) {
+	ceremony := newTestCeremony()
+	updatedCeremony := ceremony.Copy()
+	if err := UpdateTranscript(updatedCeremony, ""); err != nil {
+		t.Fatal(err)
+	}
+	// Inconsistent powers are only caught by the pairing check
+	powers := updatedCeremony.Transcripts[0].PowersOfTau.G1Powers
+	powers[2] = powers[1]
+	if err := CheckSubmission(ceremony, updatedCeremony, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySubmission(ceremony, updatedCeremony, ""); err == nil {
+		t.Fatal("inconsistent powers accepted")
+	}
+}
+
+func TestWitnessContinuity(t *testing.T) {
+	ceremony := newTestCeremony()
+	updatedCeremony := ceremony.Copy()
+	if err := UpdateTranscript(updatedCeremony, ""); err != nil {
+		t.Fatal(err)
+	}
+	// A contribution on top of another state does not extend the witness
+	other := ceremony.Copy()
+	if err := UpdateTranscript(other, ""); err != nil {
+		t.Fatal(err)
+	}
+	forked := updatedCeremony.Copy()
+	if err := UpdateTranscript(forked, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := WitnessContinuityCheck(other, forked); err == nil {
+		t.Fatal("non-extending witness accepted")
+	}
+	if err := CheckSubmission(other, forked, ""); err == nil {
+		t.Fatal("non-extending witness accepted")
+	}
+	// Neither a resubmission of the previous state nor skipped contributions are accepted
+	if err := CheckSubmission(updatedCeremony, updatedCeremony, ""); err == nil {
+		t.Fatal("unchanged witness accepted")
+	}
+	if err := CheckSubmission(ceremony, forked, ""); err == nil {
+		t.Fatal("two contributions accepted")
+	}
+	if err := WitnessContinuityCheck(ceremony, forked); err == nil {
+		t.Fatal("two contributions accepted")
+	}
+	if err := CheckSubmission(ceremony, ceremony.TranscriptCeremony(0), ""); err == nil {
+		t.Fatal("missing transcript accepted")
+	}
+}
+
+func TestTrivialContributions(t *testing.T) {
+	ceremony := newTestCeremony()
+	one := make([]byte, 32)
+	one[31] = 1
+
+	// A secret of 1 keeps the powers and adds the generator as pot pubkey
+	trivial := ceremony.Copy()
+	for _, transcript := range trivial.Transcripts {
+		if err := UpdatePowersOfTau(transcript, one); err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateWitness(transcript, one); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if TrivialSecretCheck(trivial) {
+		t.Fatal("generator accepted as pot pubkey")
+	}
+	if err := VerifySubmission(ceremony, trivial, ""); err == nil {
+		t.Fatal("contribution with secret 1 accepted")
+	}
+
+	infinity := trivial.Copy()
+	for _, transcript := range infinity.Transcripts {
+		transcript.Witness.PotPubkeys[len(transcript.Witness.PotPubkeys)-1] = blst.P2Affine{}
+	}
+	if TrivialSecretCheck(infinity) {
+		t.Fatal("infinity accepted as pot pubkey")
+	}
+
+	// A fresh pot pubkey without updated powers
+	replayed := ceremony.Copy()
+	secret := make([]byte, 32)
+	secret[31] = 42
+	for _, transcript := range replayed.Transcripts {
+		if err := UpdateWitness(transcript, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !TrivialSecretCheck(replayed) {
+		t.Fatal("valid pot pubkey rejected")
+	}
+	if PowersChangedCheck(ceremony, replayed) {
+		t.Fatal("unchanged powers accepted")
+	}
+	if err := CheckSubmission(ceremony, replayed, ""); err == nil {
+		t.Fatal("contribution with unchanged powers accepted")
+	}
+}
+
+func TestPowersWitnessCheck(t *testing.T) {
+	ceremony := newTestCeremony()
+	if !PowersWitnessCheck(ceremony) {
+		t.Fatal("initial ceremony rejected")
+	}
+	updated := ceremony.Copy()
+	if err := UpdateTranscript(updated, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !PowersWitnessCheck(updated) {
+		t.Fatal("valid contribution rejected")
+	}
+
+	// Powers and witness updated with different secrets
+	secret := make([]byte, 32)
+	secret[31] = 42
+	mismatched := updated.Copy()
+	for _, transcript := range mismatched.Transcripts {
+		if err := UpdateWitness(transcript, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if PowersWitnessCheck(mismatched) {
+		t.Fatal("witness not matching the powers accepted")
+	}
+}
@@ -0,0 +1,37 @@
+package towersofpau
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// SolveChallenge searches a solution for the hashcash-style challenge,
+// i.e. a number s.th. sha256(nonce || solution) starts with difficulty zero bits.
+func SolveChallenge(nonce []byte, difficulty int) uint64 {
+	var solution uint64
+	for !VerifyChallengeSolution(nonce, difficulty, solution) {
+		solution++
+	}
+	return solution
+}
+
+// VerifyChallengeSolution checks that solution solves the challenge.
+func VerifyChallengeSolution(nonce []byte, difficulty int, solution uint64) bool {
+	buf := make([]byte, len(nonce)+8)
+	copy(buf, nonce)
+	binary.BigEndian.PutUint64(buf[len(nonce):], solution)
+	hash := sha256.Sum256(buf)
+	return leadingZeroBits(hash[:]) >= difficulty
+}
+
+func leadingZeroBits(hash []byte) int {
+	var zeros int
+	for _, b := range hash {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros
+}
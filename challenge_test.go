@@ -0,0 +1,17 @@
+package towersofpau
+
+import "testing"
+
+func TestChallenge(t *testing.T) {
+	nonce := []byte("towers of pau")
+	solution := SolveChallenge(nonce, 12)
+	if !VerifyChallengeSolution(nonce, 12, solution) {
+		t.Fatal("solution not accepted")
+	}
+	if VerifyChallengeSolution(nonce, 64, solution) {
+		t.Fatal("solution accepted for higher difficulty")
+	}
+	if leadingZeroBits([]byte{0, 0x10, 0xff}) != 11 {
+		t.Fatal("wrong number of leading zero bits")
+	}
+}
@@ -0,0 +1,97 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// checkpointDomain separates checkpoint signatures from other signatures
+const checkpointDomain = "towersofpau checkpoint\n"
+
+// Checkpoint is the state of the history of a ceremony as seen by a mirror or participant. Observers
+// exchange their checkpoints to detect a coordinator showing different histories to different parties.
+type Checkpoint struct {
+	Ceremony string
+	// Count is the number of contributions, CeremonyHash the hash of the ceremony after the last one
+	Count        int
+	CeremonyHash string
+	// TreeHead is the tree head of size Count signed by the coordinator, it makes conflicting
+	// checkpoints attributable to the coordinator
+	TreeHead  SignedTreeHead
+	Timestamp int64
+}
+
+// SignedCheckpoint is signed by the observer, not by the coordinator.
+type SignedCheckpoint struct {
+	Checkpoint Checkpoint
+	PublicKey  string
+	Signature  string
+}
+
+// SignCheckpoint signs the checkpoint with the key of the observer.
+func SignCheckpoint(checkpoint Checkpoint, key ed25519.PrivateKey) (*SignedCheckpoint, error) {
+	pub, sig, err := signJSON(checkpointDomain, checkpoint, key)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedCheckpoint{
+		Checkpoint: checkpoint,
+		PublicKey:  pub,
+		Signature:  sig,
+	}, nil
+}
+
+// Verify checks the signature of the observer and the tree head of the coordinator. If coordinatorKey
+// is nil, any key is accepted for the tree head.
+func (c *SignedCheckpoint) Verify(coordinatorKey ed25519.PublicKey) error {
+	if err := verifyJSON(checkpointDomain, c.Checkpoint, c.PublicKey, c.Signature, nil, "checkpoint"); err != nil {
+		return err
+	}
+	head := &c.Checkpoint.TreeHead
+	if err := head.Verify(coordinatorKey); err != nil {
+		return err
+	}
+	if head.TreeHead.Size != c.Checkpoint.Count || head.TreeHead.Ceremony != c.Checkpoint.Ceremony {
+		return errors.New("tree head does not match the checkpoint")
+	}
+	return nil
+}
+
+// ConflictingCheckpoints returns an error describing the split view if both checkpoints can not be true.
+// Checkpoints of different counts can only be compared with a consistency proof, see ConsistentCheckpoints.
+func ConflictingCheckpoints(a, b *Checkpoint) error {
+	if a.Ceremony != b.Ceremony || a.Count != b.Count {
+		return nil
+	}
+	if a.CeremonyHash != b.CeremonyHash {
+		return fmt.Errorf("split view: ceremony %v after %v contributions differs", a.CeremonyHash, a.Count)
+	}
+	if a.TreeHead.TreeHead.RootHash != b.TreeHead.TreeHead.RootHash {
+		return fmt.Errorf("split view: log of %v contributions differs", a.Count)
+	}
+	return nil
+}
+
+// ConsistentCheckpoints checks with the consistency proof of the coordinator that the history of the
+// smaller checkpoint is a prefix of the history of the larger one.
+func ConsistentCheckpoints(a, b *Checkpoint, proof [][32]byte) error {
+	if err := ConflictingCheckpoints(a, b); err != nil {
+		return err
+	}
+	if a.Count > b.Count {
+		a, b = b, a
+	}
+	first, err := a.TreeHead.TreeHead.Root()
+	if err != nil {
+		return err
+	}
+	second, err := b.TreeHead.TreeHead.Root()
+	if err != nil {
+		return err
+	}
+	if !VerifyConsistency(a.Count, b.Count, first, second, proof) {
+		return fmt.Errorf("split view: log of %v contributions is not a prefix of the log of %v contributions", a.Count, b.Count)
+	}
+	return nil
+}
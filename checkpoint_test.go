@@ -0,0 +1,71 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func testCheckpoint(t *testing.T, coordinator, observer ed25519.PrivateKey, leaves [][32]byte) *SignedCheckpoint {
+	root := MerkleRoot(leaves)
+	head, err := SignTreeHead(TreeHead{Size: len(leaves), RootHash: EncodeHashes([][32]byte{root})[0]}, coordinator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := SignCheckpoint(Checkpoint{
+		Count:        len(leaves),
+		CeremonyHash: EncodeHashes(leaves[len(leaves)-1:])[0],
+		TreeHead:     *head,
+	}, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checkpoint
+}
+
+func TestCheckpoints(t *testing.T) {
+	coordinatorPub, coordinator, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, observer, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves := testLeaves(7)
+	small := testCheckpoint(t, coordinator, observer, leaves[:5])
+	large := testCheckpoint(t, coordinator, observer, leaves)
+	if err := small.Verify(coordinatorPub); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConflictingCheckpoints(&small.Checkpoint, &testCheckpoint(t, coordinator, observer, leaves[:5]).Checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	proof := ConsistencyProof(leaves, 5)
+	if err := ConsistentCheckpoints(&small.Checkpoint, &large.Checkpoint, proof); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConsistentCheckpoints(&large.Checkpoint, &small.Checkpoint, proof); err != nil {
+		t.Fatal(err)
+	}
+
+	forked := append(append([][32]byte{}, leaves[:4]...), MerkleLeafHash([]byte("fork")))
+	fork := testCheckpoint(t, coordinator, observer, forked)
+	if err := ConflictingCheckpoints(&small.Checkpoint, &fork.Checkpoint); err == nil {
+		t.Fatal("split view not detected")
+	}
+	if err := ConsistentCheckpoints(&fork.Checkpoint, &large.Checkpoint, proof); err == nil {
+		t.Fatal("split view not detected with consistency proof")
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := small.Verify(otherPub); err == nil {
+		t.Fatal("checkpoint accepted for other coordinator key")
+	}
+	small.Checkpoint.Count = 6
+	if err := small.Verify(coordinatorPub); err == nil {
+		t.Fatal("modified checkpoint accepted")
+	}
+}
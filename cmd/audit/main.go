@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func main() {
+	coordinatorKeyHex := flag.String("coordinator-key", "", "hex encoded ed25519 public key the log has to be signed with")
+	ceremonyID := flag.String("ceremony", "main", "id of the ceremony to audit")
+	history := flag.Bool("history", true, "check that the published ceremonies match the log")
+	treeHeadPath := flag.String("tree-head", "", "signed tree head seen earlier, e.g. treehead.json of a participant, the current tree has to extend it")
+	beaconValue := flag.String("beacon", "", "hex encoded beacon value announced for the finalization, the ceremony has to be finalized with it")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal("invalid amount of args, need coordinator url")
+	}
+	url := fmt.Sprintf("%v/ceremonies/%v", flag.Arg(0), *ceremonyID)
+	var coordinatorKey ed25519.PublicKey
+	if *coordinatorKeyHex != "" {
+		coordinatorKey = common.FromHex(*coordinatorKeyHex)
+		if len(coordinatorKey) != ed25519.PublicKeySize {
+			log.Fatal("invalid coordinator key")
+		}
+	}
+
+	// The tree head is fetched first, s.th. the log is at least as large
+	var head towersofpau.SignedTreeHead
+	body, err := get(url + "/tree/head")
+	if err != nil {
+		log.Fatal("unable to fetch tree head ", err.Error())
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		log.Fatal("invalid tree head ", err.Error())
+	}
+
+	var entries []towersofpau.SignedLogEntry
+	body, err = get(url + "/log")
+	if err != nil {
+		log.Fatal("unable to fetch log ", err.Error())
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		log.Fatal("invalid log ", err.Error())
+	}
+	if err := towersofpau.VerifyLog(entries, coordinatorKey); err != nil {
+		log.Fatal("log verification failed: ", err.Error())
+	}
+	for _, entry := range entries {
+		if entry.Entry.Ceremony != *ceremonyID {
+			log.Fatalf("entry %v belongs to ceremony %v", entry.Entry.Index, entry.Entry.Ceremony)
+		}
+	}
+	fmt.Printf("Log of %v entries is complete and unaltered\n", len(entries))
+	beacon, err := checkBeaconEntry(entries, *beaconValue)
+	if err != nil {
+		log.Fatal("beacon verification failed: ", err.Error())
+	}
+	if err := checkTreeHead(head, entries, coordinatorKey); err != nil {
+		log.Fatal("tree head verification failed: ", err.Error())
+	}
+	fmt.Printf("Tree head of size %v matches the log\n", head.TreeHead.Size)
+	if *treeHeadPath != "" {
+		if err := checkConsistency(url, *treeHeadPath, head, coordinatorKey); err != nil {
+			log.Fatal("consistency check failed: ", err.Error())
+		}
+		fmt.Printf("Tree head in %v is consistent with the current tree\n", *treeHeadPath)
+	}
+
+	if *history {
+		var prev *towersofpau.Ceremony
+		for _, entry := range entries {
+			serialized, err := get(fmt.Sprintf("%v/history/%d", url, entry.Entry.Index))
+			if err != nil {
+				log.Fatalf("unable to fetch ceremony %v: %v", entry.Entry.Index, err)
+			}
+			ceremony, err := towersofpau.Deserialize(bytes.NewReader(serialized))
+			if err != nil {
+				log.Fatalf("invalid ceremony %v: %v", entry.Entry.Index, err)
+			}
+			root, err := ceremony.HashTreeRoot()
+			if err != nil || "0x"+hex.EncodeToString(root[:]) != entry.Entry.CeremonyHash {
+				log.Fatalf("published ceremony %v does not match the log", entry.Entry.Index)
+			}
+			if entry.Entry.Beacon != nil {
+				if err := towersofpau.VerifyBeacon(prev, ceremony, *entry.Entry.Beacon); err != nil {
+					log.Fatal("beacon verification failed: ", err.Error())
+				}
+				fmt.Println("Recomputed the beacon contribution")
+			}
+			prev = ceremony
+		}
+		fmt.Println("All published ceremonies match the log")
+	}
+	if beacon != nil {
+		fmt.Printf("Ceremony finalized with beacon %v after %v rounds\n", beacon.Value, beacon.Rounds)
+	}
+	if len(entries) > 0 {
+		head, err := entries[len(entries)-1].Entry.Hash()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Log head %v signed by %v\n", head, entries[0].PublicKey)
+	}
+}
+
+// checkBeaconEntry checks that only the last entry of the log is a beacon contribution and returns its beacon.
+// If value is not empty the ceremony has to be finalized with it.
+func checkBeaconEntry(entries []towersofpau.SignedLogEntry, value string) (*towersofpau.Beacon, error) {
+	var beacon *towersofpau.Beacon
+	for i, entry := range entries {
+		if entry.Entry.Beacon == nil {
+			continue
+		}
+		if i == 0 || i != len(entries)-1 {
+			return nil, fmt.Errorf("beacon contribution at entry %v, it has to be the last entry and follow a participant", i)
+		}
+		if entry.Entry.Identity != entry.Entry.Beacon.Identity() {
+			return nil, errors.New("identity of the beacon entry does not match its beacon")
+		}
+		beacon = entry.Entry.Beacon
+	}
+	if value != "" && (beacon == nil || !strings.EqualFold(beacon.Value, value)) {
+		return nil, fmt.Errorf("ceremony is not finalized with beacon %v", value)
+	}
+	return beacon, nil
+}
+
+// checkTreeHead checks that the signed tree head commits to the first entries of the log.
+func checkTreeHead(head towersofpau.SignedTreeHead, entries []towersofpau.SignedLogEntry, key ed25519.PublicKey) error {
+	if key == nil && len(entries) > 0 {
+		key = common.FromHex(entries[0].PublicKey)
+	}
+	if err := head.Verify(key); err != nil {
+		return err
+	}
+	if head.TreeHead.Size > len(entries) {
+		return fmt.Errorf("tree of size %v is larger than the log", head.TreeHead.Size)
+	}
+	leaves := make([][32]byte, head.TreeHead.Size)
+	for i := range leaves {
+		var err error
+		if leaves[i], err = entries[i].Entry.LeafHash(); err != nil {
+			return err
+		}
+	}
+	root, err := head.TreeHead.Root()
+	if err != nil {
+		return err
+	}
+	if towersofpau.MerkleRoot(leaves) != root {
+		return errors.New("root hash does not match the log")
+	}
+	return nil
+}
+
+// checkConsistency checks the proof that the current tree extends the tree head saved at path.
+func checkConsistency(url, path string, head towersofpau.SignedTreeHead, key ed25519.PublicKey) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var old towersofpau.SignedTreeHead
+	if err := json.Unmarshal(data, &old); err != nil {
+		return err
+	}
+	if key == nil {
+		key = common.FromHex(head.PublicKey)
+	}
+	if err := old.Verify(key); err != nil {
+		return err
+	}
+	if old.TreeHead.Ceremony != head.TreeHead.Ceremony {
+		return fmt.Errorf("tree head belongs to ceremony %v", old.TreeHead.Ceremony)
+	}
+	body, err := get(fmt.Sprintf("%v/tree/consistency?first=%v&second=%v", url, old.TreeHead.Size, head.TreeHead.Size))
+	if err != nil {
+		return err
+	}
+	var proof towersofpau.LogConsistencyProof
+	if err := json.Unmarshal(body, &proof); err != nil {
+		return err
+	}
+	hashes, err := towersofpau.DecodeHashes(proof.Hashes)
+	if err != nil {
+		return err
+	}
+	oldRoot, err := old.TreeHead.Root()
+	if err != nil {
+		return err
+	}
+	root, err := head.TreeHead.Root()
+	if err != nil {
+		return err
+	}
+	if !towersofpau.VerifyConsistency(old.TreeHead.Size, head.TreeHead.Size, oldRoot, root, hashes) {
+		return errors.New("the history was rewritten since the tree head was signed")
+	}
+	return nil
+}
+
+func get(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %v", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// Finalize closes the ceremony with the contribution derived from the beacon in the request. It is only
+// possible once nobody is queued or contributing anymore, so registration should be closed first.
+func (c *Coordinator) Finalize(rw http.ResponseWriter, req *http.Request) {
+	var beacon towersofpau.Beacon
+	if err := json.NewDecoder(req.Body).Decode(&beacon); err != nil {
+		http.Error(rw, "invalid beacon", 400)
+		return
+	}
+	if _, err := beacon.Secrets(0); err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+
+	c.ceremonyMutex.Lock()
+	defer c.ceremonyMutex.Unlock()
+	c.mutex.Lock()
+	c.advanceSlots()
+	if err := c.checkFinalize(); err != nil {
+		c.mutex.Unlock()
+		http.Error(rw, err.Error(), 409)
+		return
+	}
+	c.beacon = &beacon
+	c.events.publish("", "queue", c.queueStatus())
+	c.mutex.Unlock()
+
+	fmt.Printf("Finalizing with beacon %v after %v rounds\n", beacon.Value, beacon.Rounds)
+	start := time.Now()
+	ceremony := c.ceremony.Copy()
+	buf := new(bytes.Buffer)
+	err := towersofpau.ApplyBeacon(ceremony, beacon)
+	if err == nil {
+		err = towersofpau.Serialize(buf, ceremony)
+	}
+	var hash string
+	if err == nil {
+		hash, err = ceremonyHash(ceremony)
+	}
+	if err == nil {
+		err = c.usePubkeys(ceremony.LatestPotPubkeys())
+	}
+	if err != nil {
+		fmt.Printf("Finalization failed: %v\n", err)
+		c.mutex.Lock()
+		c.beacon = nil
+		c.events.publish("", "queue", c.queueStatus())
+		c.mutex.Unlock()
+		http.Error(rw, err.Error(), 500)
+		return
+	}
+	fmt.Printf("Beacon contribution computed in %v\n", time.Since(start))
+	c.ceremony = ceremony
+
+	c.historyMutex.Lock()
+	contribution := towersofpau.Contribution{
+		Index:        len(c.history),
+		Slot:         -1,
+		Timestamp:    time.Now().Unix(),
+		Identity:     beacon.Identity(),
+		PotPubkeys:   ceremony.LatestPotPubkeys(),
+		CeremonyHash: hash,
+		Beacon:       &beacon,
+	}
+	c.publishContribution(contribution, buf.Bytes())
+	c.historyMutex.Unlock()
+	writeJSON(rw, contribution)
+}
+
+// checkFinalize returns why the ceremony can not be finalized right now, mutex has to be held.
+func (c *Coordinator) checkFinalize() error {
+	if c.beacon != nil {
+		return errFinalized
+	}
+	if c.currentSlot < len(c.slots) || len(c.pending) > 0 {
+		return errors.New("participants are still queued or contributing")
+	}
+	c.historyMutex.RLock()
+	defer c.historyMutex.RUnlock()
+	// The secrets of the beacon are public, it only adds to the secrets of earlier participants
+	if len(c.history) == 0 {
+		return errors.New("nobody contributed yet")
+	}
+	return nil
+}
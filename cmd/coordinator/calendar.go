@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/gorilla/mux"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// checkWindow validates a window requested at registration.
+func (c *Coordinator) checkWindow(window towersofpau.TimeWindow, length int64) error {
+	maxAhead := c.config.Slots.MaxBookingAhead
+	if maxAhead <= 0 {
+		return errors.New("booking windows are disabled")
+	}
+	if window.End != 0 && window.End-window.Start < length {
+		return fmt.Errorf("window is shorter than the slot length of %v seconds", length)
+	}
+	if window.End != 0 && window.End < time.Now().Unix() {
+		return errors.New("window is in the past")
+	}
+	if window.Start > time.Now().Unix()+maxAhead {
+		return fmt.Errorf("window starts more than %v seconds ahead", maxAhead)
+	}
+	return nil
+}
+
+// bookingConflict rejects a registration whose window is taken and offers alternatives.
+func bookingConflict(rw http.ResponseWriter, reason string, alternatives []towersofpau.TimeWindow) {
+	resp, err := json.Marshal(towersofpau.BookingConflict{
+		Reason:       reason,
+		Alternatives: alternatives,
+	})
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusConflict)
+	rw.Write(resp)
+}
+
+// Calendar returns the slot of the participant as an iCalendar event.
+func (c *Coordinator) Calendar(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advanceSlots()
+	slot := c.slotByTicket[mux.Vars(req)["ticket"]]
+	if slot == nil || c.slotFinished(slot) {
+		rw.WriteHeader(403)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	rw.Header().Set("Content-Disposition", "attachment; filename=\"slot.ics\"")
+	rw.Write([]byte(slotEvent(slot, req.Host)))
+}
+
+// slotEvent renders an iCalendar file with a single event covering the slot.
+func slotEvent(slot *slot, host string) string {
+	// The ticket is secret, so the event is identified by its hash
+	uid := strings.TrimPrefix(hashBytes([]byte(slot.participantTicket)), "0x")
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//towersofpau//coordinator//EN",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:%v@%v", uid, host),
+		"DTSTAMP:" + time.Now().UTC().Format(icsTimeFormat),
+		"DTSTART:" + time.Unix(slot.start, 0).UTC().Format(icsTimeFormat),
+		"DTEND:" + time.Unix(slot.deadline, 0).UTC().Format(icsTimeFormat),
+		"SUMMARY:KZG ceremony contribution",
+		fmt.Sprintf("DESCRIPTION:Contribution slot no. %v at %v", slot.index, host),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
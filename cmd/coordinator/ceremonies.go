@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// mainCeremony is the ID of the ceremony given on the command line, it is also served at the root.
+const mainCeremony = "main"
+
+var ceremonyID = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// startCeremony reads the initial ceremony at path and starts its coordinator.
+func startCeremony(id, path string, config Config, key ed25519.PrivateKey) (*Coordinator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fmt.Printf("Reading initial ceremony of %v\n", id)
+	ceremony, err := towersofpau.Deserialize(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(config.HistoryDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	coordinator, err := NewCoordinator(id, ceremony, config, key)
+	if err != nil {
+		return nil, err
+	}
+	go coordinator.runScheduler()
+	return coordinator, nil
+}
+
+// startCeremonies starts the additional ceremonies of the config.
+func startCeremonies(config Config, mainKey ed25519.PrivateKey) ([]*Coordinator, error) {
+	var coordinators []*Coordinator
+	seen := map[string]bool{mainCeremony: true}
+	for _, ceremony := range config.Ceremonies {
+		if !ceremonyID.MatchString(ceremony.ID) || seen[ceremony.ID] {
+			return nil, fmt.Errorf("invalid or duplicate ceremony id %q", ceremony.ID)
+		}
+		seen[ceremony.ID] = true
+		ceremonyConfig := DefaultConfig()
+		if ceremony.Config != "" {
+			var err error
+			if ceremonyConfig, err = LoadConfig(ceremony.Config); err != nil {
+				return nil, fmt.Errorf("config of %v: %v", ceremony.ID, err)
+			}
+		}
+		if len(ceremonyConfig.Ceremonies) != 0 {
+			return nil, errors.New("ceremonies can not be nested")
+		}
+		if ceremonyConfig.HistoryDir == historyDir {
+			ceremonyConfig.HistoryDir = filepath.Join(historyDir, ceremony.ID)
+		}
+		key := mainKey
+		if ceremonyConfig.ReceiptKey != "" || ceremonyConfig.ReceiptKeyFile != receiptKeyFile {
+			var err error
+			if key, err = receiptKey(ceremonyConfig); err != nil {
+				return nil, fmt.Errorf("receipt key of %v: %v", ceremony.ID, err)
+			}
+		}
+		coordinator, err := startCeremony(ceremony.ID, ceremony.InitialCeremony, ceremonyConfig, key)
+		if err != nil {
+			return nil, fmt.Errorf("ceremony %v: %v", ceremony.ID, err)
+		}
+		fmt.Printf("Started ceremony %v, receipts are signed with key 0x%x\n", ceremony.ID, key.Public())
+		coordinators = append(coordinators, coordinator)
+	}
+	return coordinators, nil
+}
+
+// ceremonyPath is the prefix the endpoints of the ceremony are served under.
+func ceremonyPath(id string) string {
+	return "/ceremonies/" + id
+}
+
+// Ceremonies lists the ceremonies run by this process.
+func Ceremonies(coordinators []*Coordinator) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		list := make([]towersofpau.CeremonyInfo, 0, len(coordinators))
+		for _, c := range coordinators {
+			c.mutex.Lock()
+			list = append(list, towersofpau.CeremonyInfo{
+				ID:     c.id,
+				Path:   ceremonyPath(c.id),
+				Status: c.queueStatus(),
+			})
+			c.mutex.Unlock()
+		}
+		resp, err := json.Marshal(list)
+		if err != nil {
+			rw.WriteHeader(500)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(resp)
+	}
+}
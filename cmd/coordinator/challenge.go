@@ -0,0 +1,132 @@
+package main
+
+import (
+	"container/heap"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/bits"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Challenges are stateless, s.th. nobody can block registrations by fetching lots of them. The nonce
+// contains the difficulty and expiry of the challenge and is authenticated with a key of the coordinator,
+// only redeemed nonces are remembered until they expire.
+
+const (
+	challengeRandomLength = 16
+	challengeMACLength    = 16
+	// challengeLength is the length of a nonce: random bytes, expiry, difficulty and MAC
+	challengeLength = challengeRandomLength + 8 + 1 + challengeMACLength
+)
+
+// IssueChallenge hands out a proof-of-work challenge that has to be solved to register.
+func (c *Coordinator) IssueChallenge(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	difficulty := c.challengeDifficulty()
+	c.mutex.Unlock()
+	expires := time.Now().Unix() + c.config.Challenge.Lifetime
+	nonce := make([]byte, challengeLength-challengeMACLength, challengeLength)
+	if _, err := rand.Read(nonce[:challengeRandomLength]); err != nil {
+		panic("invalid randomness")
+	}
+	binary.BigEndian.PutUint64(nonce[challengeRandomLength:], uint64(expires))
+	nonce[len(nonce)-1] = byte(difficulty)
+	nonce = append(nonce, c.challengeMAC(nonce)...)
+	resp, err := json.Marshal(towersofpau.Challenge{
+		Nonce:      common.Bytes2Hex(nonce),
+		Difficulty: difficulty,
+		Expires:    expires,
+	})
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Write(resp)
+}
+
+// challengeMAC authenticates the nonce without MAC.
+func (c *Coordinator) challengeMAC(nonce []byte) []byte {
+	mac := hmac.New(sha256.New, c.challengeKey)
+	mac.Write(nonce)
+	return mac.Sum(nil)[:challengeMACLength]
+}
+
+// challengeDifficulty increases the difficulty each time the queue length doubles.
+func (c *Coordinator) challengeDifficulty() int {
+	config := c.config.Challenge
+	queueLength := len(c.slots) - c.currentSlot
+	difficulty := config.BaseDifficulty + config.DifficultyPerDoubling*bits.Len(uint(queueLength))
+	if config.MaxDifficulty > 0 && difficulty > config.MaxDifficulty {
+		difficulty = config.MaxDifficulty
+	}
+	if difficulty > 255 {
+		difficulty = 255
+	}
+	return difficulty
+}
+
+// challengeNonce is a challenge nonce that can be redeemed until it expires.
+type challengeNonce struct {
+	key     string
+	expires int64
+}
+
+// challengeQueue orders the redeemed challenges by expiry, s.th. they can be forgotten without
+// scanning all of them. It implements heap.Interface.
+type challengeQueue []challengeNonce
+
+func (q challengeQueue) Len() int            { return len(q) }
+func (q challengeQueue) Less(i, j int) bool  { return q[i].expires < q[j].expires }
+func (q challengeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *challengeQueue) Push(x interface{}) { *q = append(*q, x.(challengeNonce)) }
+func (q *challengeQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// checkChallenge verifies the challenge of the request and its solution without redeeming it, s.th. a
+// registration that is rejected for another reason can be retried with the same solution. Mutex has to be held.
+func (c *Coordinator) checkChallenge(request *towersofpau.RegistrationRequest) (challengeNonce, error) {
+	nonce := common.FromHex(request.Nonce)
+	if len(nonce) != challengeLength {
+		return challengeNonce{}, errors.New("unknown challenge")
+	}
+	signed, mac := nonce[:challengeLength-challengeMACLength], nonce[challengeLength-challengeMACLength:]
+	if !hmac.Equal(mac, c.challengeMAC(signed)) {
+		return challengeNonce{}, errors.New("unknown challenge")
+	}
+	expires := int64(binary.BigEndian.Uint64(signed[challengeRandomLength:]))
+	if expires < time.Now().Unix() {
+		return challengeNonce{}, errors.New("challenge expired")
+	}
+	key := common.Bytes2Hex(nonce)
+	if c.redeemed[key] {
+		return challengeNonce{}, errors.New("challenge already used")
+	}
+	difficulty := int(signed[len(signed)-1])
+	if !towersofpau.VerifyChallengeSolution(nonce, difficulty, request.Solution) {
+		return challengeNonce{}, errors.New("invalid challenge solution")
+	}
+	return challengeNonce{key: key, expires: expires}, nil
+}
+
+// redeemChallenge marks the checked challenge as used until it expires and forgets the expired ones.
+// Mutex has to be held.
+func (c *Coordinator) redeemChallenge(challenge challengeNonce) {
+	now := time.Now().Unix()
+	for len(c.redeemedQueue) > 0 && c.redeemedQueue[0].expires < now {
+		delete(c.redeemed, heap.Pop(&c.redeemedQueue).(challengeNonce).key)
+	}
+	c.redeemed[challenge.key] = true
+	heap.Push(&c.redeemedQueue, challenge)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// solveChallenge fetches a challenge from c and returns a registration request with its solution.
+func solveChallenge(t *testing.T, c *Coordinator) *towersofpau.RegistrationRequest {
+	rw := httptest.NewRecorder()
+	c.IssueChallenge(rw, httptest.NewRequest("GET", "/participation/challenge", nil))
+	var challenge towersofpau.Challenge
+	if err := json.Unmarshal(rw.Body.Bytes(), &challenge); err != nil {
+		t.Fatal(err)
+	}
+	return &towersofpau.RegistrationRequest{
+		Nonce:    challenge.Nonce,
+		Solution: towersofpau.SolveChallenge(common.Hex2Bytes(challenge.Nonce), challenge.Difficulty),
+	}
+}
+
+func TestChallenges(t *testing.T) {
+	c := &Coordinator{
+		config:       Config{Challenge: ChallengeConfig{BaseDifficulty: 4, Lifetime: 60}},
+		challengeKey: []byte("challenge key"),
+		redeemed:     make(map[string]bool),
+	}
+	request := solveChallenge(t, c)
+	nonce := common.Hex2Bytes(request.Nonce)
+	if difficulty := nonce[challengeLength-challengeMACLength-1]; difficulty != 4 {
+		t.Fatalf("difficulty %v", difficulty)
+	}
+
+	// Lowering the difficulty invalidates the nonce
+	tampered := append([]byte{}, nonce...)
+	tampered[challengeLength-challengeMACLength-1] = 0
+	if _, err := c.checkChallenge(&towersofpau.RegistrationRequest{
+		Nonce:    common.Bytes2Hex(tampered),
+		Solution: towersofpau.SolveChallenge(tampered, 0),
+	}); err == nil {
+		t.Fatal("tampered challenge accepted")
+	}
+
+	// Checking a challenge does not use it up, redeeming it does
+	for i := 0; i < 2; i++ {
+		if _, err := c.checkChallenge(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	challenge, _ := c.checkChallenge(request)
+	c.redeemChallenge(challenge)
+	if _, err := c.checkChallenge(request); err == nil {
+		t.Fatal("challenge accepted twice")
+	}
+	// Another coordinator does not accept the challenge
+	other := &Coordinator{challengeKey: []byte("other key"), redeemed: make(map[string]bool)}
+	if _, err := other.checkChallenge(request); err == nil {
+		t.Fatal("challenge of another coordinator accepted")
+	}
+
+	// Expired challenges are forgotten in order of their expiry
+	now := time.Now().Unix()
+	c.redeemChallenge(challengeNonce{key: "b", expires: now - 1})
+	c.redeemChallenge(challengeNonce{key: "a", expires: now - 2})
+	c.redeemChallenge(challengeNonce{key: "c", expires: now + 60})
+	if len(c.redeemed) != 2 || !c.redeemed["c"] || c.redeemed["a"] || c.redeemed["b"] || !c.redeemed[challenge.key] {
+		t.Fatalf("redeemed challenges: %v", c.redeemed)
+	}
+}
+
+func TestChallengeRetry(t *testing.T) {
+	config := DefaultConfig()
+	config.HistoryDir = t.TempDir()
+	config.Challenge.BaseDifficulty = 4
+	config.Challenge.DifficultyPerDoubling = 0
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCoordinator(mainCeremony, testCeremony(), config, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := func(request *towersofpau.RegistrationRequest) int {
+		body, err := json.Marshal(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		c.RegisterParticipant(rw, httptest.NewRequest("POST", "/participation", bytes.NewReader(body)))
+		return rw.Code
+	}
+
+	// A registration rejected for its window can be retried with the same solution
+	request := solveChallenge(t, c)
+	request.WindowStart = time.Now().Unix() - 600
+	request.WindowEnd = time.Now().Unix() - 300
+	if code := register(request); code != 400 {
+		t.Fatalf("registration for a past window returned %v", code)
+	}
+	request.WindowStart, request.WindowEnd = 0, 0
+	if code := register(request); code != 200 {
+		t.Fatalf("retried registration returned %v", code)
+	}
+	if code := register(request); code != 403 {
+		t.Fatalf("second registration with the same solution returned %v", code)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Config holds the tunable parameters of the coordinator.
+type Config struct {
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	Challenge ChallengeConfig `json:"challenge"`
+	Identity  IdentityConfig  `json:"identity"`
+	// ReceiptKey is the hex encoded ed25519 seed submission receipts are signed with
+	ReceiptKey string `json:"receiptKey"`
+	// ReceiptKeyFile keeps the receipt key if ReceiptKey is empty, it is generated on the first start.
+	// It must not be in HistoryDir, which is published.
+	ReceiptKeyFile string       `json:"receiptKeyFile"`
+	Slots          SlotConfig   `json:"slots"`
+	Policy         PolicyConfig `json:"policy"`
+	// Verification configures who has to approve a submission before it is accepted
+	Verification VerificationConfig `json:"verification"`
+	// AdminToken is the bearer token required by the admin API, the admin API is disabled if empty
+	AdminToken string `json:"adminToken"`
+	// Pipelined hands out the transcripts individually, s.th. the next participant can start
+	// on a transcript as soon as the previous participant submitted it
+	Pipelined bool `json:"pipelined"`
+	// Optimistic hands on a submission to the next participant before its pairing check finished,
+	// if the check fails the state is rolled back. Not supported in pipelined mode.
+	Optimistic bool `json:"optimistic"`
+	// HistoryDir is the directory accepted ceremonies are published in
+	HistoryDir string `json:"historyDir"`
+	// Ceremonies are run next to the main ceremony, each with its own queue, history and config
+	Ceremonies []CeremonyConfig `json:"ceremonies"`
+}
+
+// CeremonyConfig describes an additional ceremony, it is served under /ceremonies/{id}/.
+type CeremonyConfig struct {
+	ID string `json:"id"`
+	// InitialCeremony is the path to the initial ceremony
+	InitialCeremony string `json:"initialCeremony"`
+	// Config is the path to the config of the ceremony, the default config is used if empty.
+	// The receipt key of the main ceremony is used if the config contains neither a key nor its own key file,
+	// history is published in history/{id} unless configured otherwise.
+	Config string `json:"config"`
+}
+
+// RateLimitConfig limits how fast participants can register.
+// A rate or limit of zero disables the corresponding check.
+type RateLimitConfig struct {
+	// PerIPRate is the number of registrations per second allowed for a single IP
+	PerIPRate  float64 `json:"perIPRate"`
+	PerIPBurst int     `json:"perIPBurst"`
+	// GlobalRate is the number of registrations per second allowed in total
+	GlobalRate  float64 `json:"globalRate"`
+	GlobalBurst int     `json:"globalBurst"`
+	// MaxQueueLength is the maximum number of participants waiting for their slot
+	MaxQueueLength int `json:"maxQueueLength"`
+	// MaxActivePerIP is the maximum number of pending registrations of a single IP
+	MaxActivePerIP int `json:"maxActivePerIP"`
+	// TrustedProxies are the IP addresses or CIDR ranges of the reverse proxies in front of the coordinator.
+	// The client IP is taken from the entries they appended to X-Forwarded-For, the header is ignored otherwise.
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+// ChallengeConfig controls the proof-of-work that is required to register.
+type ChallengeConfig struct {
+	// BaseDifficulty is the number of leading zero bits required with an empty queue
+	BaseDifficulty int `json:"baseDifficulty"`
+	// DifficultyPerDoubling is added to the difficulty every time the queue length doubles
+	DifficultyPerDoubling int `json:"difficultyPerDoubling"`
+	MaxDifficulty         int `json:"maxDifficulty"`
+	// Lifetime is the number of seconds a challenge can be solved in
+	Lifetime int64 `json:"lifetime"`
+}
+
+// IdentityConfig controls how participants identify themselves.
+type IdentityConfig struct {
+	// RequireEthereum rejects registrations without a sign-in with ethereum
+	RequireEthereum bool `json:"requireEthereum"`
+	// Domain the coordinator is served at, e.g. ceremony.example.org, participants sign in for it.
+	// Sign-in with ethereum is disabled if empty.
+	Domain string `json:"domain"`
+	// RequireBLSSignatures requires participants with an identity to sign it with the secret of every transcript,
+	// disabled by default s.th. participants that don't sign their identity are still accepted
+	RequireBLSSignatures bool `json:"requireBLSSignatures"`
+}
+
+// SlotConfig bounds the time participants get to contribute.
+type SlotConfig struct {
+	// MinParticipantTime and MaxParticipantTime bound the slot length in seconds
+	MinParticipantTime int64 `json:"minParticipantTime"`
+	MaxParticipantTime int64 `json:"maxParticipantTime"`
+	// SafetyFactor is multiplied with the contribution time estimated from the benchmark of the participant
+	SafetyFactor float64 `json:"safetyFactor"`
+	// DefaultThroughput is the assumed network throughput in bytes per second until it has been measured
+	DefaultThroughput float64 `json:"defaultThroughput"`
+	// HeartbeatTimeout is the number of seconds without heartbeat after which a slot is released
+	HeartbeatTimeout int64 `json:"heartbeatTimeout"`
+	// MaxBookingAhead is the number of seconds in advance a window can be booked, 0 disables bookings
+	MaxBookingAhead int64 `json:"maxBookingAhead"`
+}
+
+// PolicyConfig decides who may register and who goes first.
+// Entries are identities like eth|0x1234..., invite codes or, for bans, IP addresses.
+type PolicyConfig struct {
+	// Closed only accepts registrations of allowlisted or prioritized participants
+	Closed    bool     `json:"closed"`
+	Allowlist []string `json:"allowlist"`
+	// Priority participants are scheduled ahead of the public queue
+	Priority []string `json:"priority"`
+	Bans     []string `json:"bans"`
+	// BanAfterStrikes bans participants that missed their slot or submitted an invalid ceremony
+	// this many times, 0 disables automatic bans
+	BanAfterStrikes int `json:"banAfterStrikes"`
+}
+
+// VerificationConfig lets remote verifiers, see cmd/verifier, cross-check the submissions.
+// Every verifier and the local verification has one vote.
+type VerificationConfig struct {
+	// Verifiers are the URLs submissions are posted to for verification
+	Verifiers []string `json:"verifiers"`
+	// Token is sent as bearer token to the verifiers
+	Token string `json:"token"`
+	// Local counts the verification by the coordinator itself as a vote
+	Local bool `json:"local"`
+	// Quorum is the number of votes needed to accept a submission, 0 requires all votes
+	Quorum int `json:"quorum"`
+	// Timeout is the number of seconds a verifier gets to answer
+	Timeout int64 `json:"timeout"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		RateLimit: RateLimitConfig{
+			PerIPRate:      1.0 / 60,
+			PerIPBurst:     3,
+			GlobalRate:     1,
+			GlobalBurst:    20,
+			MaxQueueLength: 500,
+			MaxActivePerIP: 2,
+		},
+		Challenge: ChallengeConfig{
+			BaseDifficulty:        18,
+			DifficultyPerDoubling: 1,
+			MaxDifficulty:         28,
+			Lifetime:              300,
+		},
+		Slots: SlotConfig{
+			MinParticipantTime: participantTime,
+			MaxParticipantTime: 600,
+			SafetyFactor:       2,
+			DefaultThroughput:  1 << 20,
+			HeartbeatTimeout:   30,
+			MaxBookingAhead:    7 * 24 * 60 * 60,
+		},
+		Policy: PolicyConfig{
+			BanAfterStrikes: 3,
+		},
+		Verification: VerificationConfig{
+			Local:   true,
+			Timeout: 120,
+		},
+		HistoryDir:     historyDir,
+		ReceiptKeyFile: receiptKeyFile,
+	}
+}
+
+// LoadConfig reads the config at path, fields missing in the file keep their default value.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	file, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// durationSmoothing is the weight of a new measurement in the moving averages
+const durationSmoothing = 0.2
+
+// durationStats keeps moving averages of how long slots take, in seconds.
+type durationStats struct {
+	contribution float64
+	verification float64
+	// throughput of submission uploads in bytes per second
+	throughput float64
+}
+
+func newDurationStats(config SlotConfig) durationStats {
+	// Until we measured anything assume that the whole budget is used
+	return durationStats{
+		contribution: participantTime,
+		verification: coordinatorTime,
+		throughput:   config.DefaultThroughput,
+	}
+}
+
+// recordContributionTime adds the time between fetching and submitting the ceremony, mutex has to be held.
+func (c *Coordinator) recordContributionTime(d time.Duration) {
+	c.durations.contribution += durationSmoothing * (d.Seconds() - c.durations.contribution)
+}
+
+// recordVerificationTime adds the time needed to verify a submission, mutex has to be held.
+func (c *Coordinator) recordVerificationTime(d time.Duration) {
+	c.durations.verification += durationSmoothing * (d.Seconds() - c.durations.verification)
+}
+
+// recordThroughput adds the upload speed of a submission, mutex has to be held.
+func (c *Coordinator) recordThroughput(bytes int, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	c.durations.throughput += durationSmoothing * (float64(bytes)/d.Seconds() - c.durations.throughput)
+}
+
+// participantTime returns the slot length in seconds for a participant with the benchmark,
+// it includes downloading and uploading the ceremony. Mutex has to be held.
+func (c *Coordinator) participantTime(benchmark *towersofpau.ContributionBenchmark) int64 {
+	config := c.config.Slots
+	contribution := float64(participantTime)
+	if benchmark != nil && benchmark.G1Mult > 0 && benchmark.G2Mult > 0 {
+		contribution = towersofpau.EstimateContributionTime(c.ceremony, *benchmark).Seconds() * config.SafetyFactor
+	}
+	c.historyMutex.RLock()
+	size := len(c.currentCeremony.body)
+	c.historyMutex.RUnlock()
+	transfer := 2 * float64(size) / c.durations.throughput
+	length := int64(math.Ceil(contribution + transfer))
+	if length < config.MinParticipantTime {
+		length = config.MinParticipantTime
+	}
+	if config.MaxParticipantTime > 0 && length > config.MaxParticipantTime {
+		length = config.MaxParticipantTime
+	}
+	return length
+}
+
+// estimatedStart walks the queue up to the slot and estimates when each slot ends,
+// slots never start before their scheduled start. Mutex has to be held.
+func (c *Coordinator) estimatedStart(slot *slot) int64 {
+	now := float64(time.Now().Unix())
+	t := now
+	for _, s := range c.slots[c.currentSlot:slot.index] {
+		start := math.Max(t, float64(s.start))
+		if !s.fetched.IsZero() {
+			start = float64(s.fetched.Unix())
+		}
+		// Participants that do not show up block the slot until the deadline
+		end := math.Min(start+c.durations.contribution, float64(s.deadline))
+		if s.submitted {
+			end = float64(s.submittedAt.Unix())
+		}
+		t = math.Max(now, end+c.durations.verification)
+	}
+	return int64(math.Max(t, float64(slot.start)))
+}
+
+// queueInfo returns the place of the slot in the queue, mutex has to be held.
+func (c *Coordinator) queueInfo(slot *slot) towersofpau.QueueInfo {
+	return towersofpau.QueueInfo{
+		Position:       slot.index - c.currentSlot + 1,
+		Ahead:          slot.index - c.currentSlot,
+		EstimatedStart: c.estimatedStart(slot),
+		ServerTime:     time.Now().Unix(),
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/gorilla/mux"
+)
+
+const (
+	keepaliveInterval = 15 * time.Second
+	// subscriberBuffer is the number of events a subscriber can lag behind before it is dropped
+	subscriberBuffer = 64
+)
+
+type event struct {
+	name string
+	data []byte
+}
+
+// eventBroker fans out events to the server-sent event streams.
+type eventBroker struct {
+	mutex sync.Mutex
+	// subscribers maps each stream to the ticket it is interested in, empty for public streams
+	subscribers map[chan event]string
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[chan event]string),
+	}
+}
+
+func (b *eventBroker) subscribe(ticket string) chan event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	ch := make(chan event, subscriberBuffer)
+	b.subscribers[ch] = ticket
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// listening returns whether a stream of ticket is connected.
+func (b *eventBroker) listening(ticket string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, subscribed := range b.subscribers {
+		if subscribed == ticket {
+			return true
+		}
+	}
+	return false
+}
+
+// publish sends an event to all streams if ticket is empty, otherwise only to the streams of ticket.
+func (b *eventBroker) publish(ticket, name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch, subscribed := range b.subscribers {
+		if ticket != "" && subscribed != ticket {
+			continue
+		}
+		select {
+		case ch <- event{name: name, data: data}:
+		default:
+			// Drop slow subscribers instead of blocking the coordinator, they will reconnect
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Events streams public updates of the ceremony.
+func (c *Coordinator) Events(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	status := c.queueStatus()
+	ch := c.events.subscribe("")
+	c.mutex.Unlock()
+	c.streamEvents(rw, req, ch, event{name: "queue", data: mustMarshal(status)})
+}
+
+// ParticipantEvents streams the public updates and the updates concerning the participant.
+func (c *Coordinator) ParticipantEvents(rw http.ResponseWriter, req *http.Request) {
+	ticket := mux.Vars(req)["ticket"]
+	c.mutex.Lock()
+	c.advanceSlots()
+	slot := c.slotByTicket[ticket]
+	if slot == nil || c.slotFinished(slot) {
+		c.mutex.Unlock()
+		rw.WriteHeader(403)
+		return
+	}
+	initial := event{name: "position", data: mustMarshal(c.queuePosition(slot))}
+	if slot.notified {
+		// The turn event was published before this stream connected, e.g. the participant reconnected
+		initial.name = "turn"
+	}
+	// Subscribing while mutex is held, s.th. no turn event is published between the initial event and the stream
+	ch := c.events.subscribe(ticket)
+	c.mutex.Unlock()
+	c.streamEvents(rw, req, ch, initial)
+}
+
+// streamEvents writes the initial event and the events sent to ch, until the client disconnects.
+func (c *Coordinator) streamEvents(rw http.ResponseWriter, req *http.Request, ch chan event, initial event) {
+	defer c.events.unsubscribe(ch)
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		rw.WriteHeader(500)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	writeEvent(rw, initial)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(rw, ev)
+		case <-keepalive.C:
+			fmt.Fprint(rw, ": keepalive\n\n")
+		case <-req.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(rw http.ResponseWriter, ev event) {
+	fmt.Fprintf(rw, "event: %v\ndata: %s\n\n", ev.name, ev.data)
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// queueStatus returns the public state of the queue, mutex has to be held.
+func (c *Coordinator) queueStatus() towersofpau.Status {
+	c.historyMutex.RLock()
+	defer c.historyMutex.RUnlock()
+	phase := phaseOpen
+	if c.beacon != nil {
+		phase = phaseFinalized
+	} else if c.policy.closed {
+		phase = phaseClosed
+	}
+	return towersofpau.Status{
+		Phase:         phase,
+		QueueLength:   len(c.slots) - c.currentSlot,
+		ActiveSlot:    c.currentSlot,
+		Registrations: len(c.slots),
+		Contributions: len(c.history),
+		ServerTime:    time.Now().Unix(),
+	}
+}
+
+// queuePosition returns the position of the slot in the queue, mutex has to be held.
+func (c *Coordinator) queuePosition(slot *slot) towersofpau.PositionEvent {
+	return towersofpau.PositionEvent{
+		Start:     slot.start,
+		Deadline:  slot.deadline,
+		QueueInfo: c.queueInfo(slot),
+	}
+}
+
+// notifyQueue informs the waiting participants about their position, mutex has to be held.
+func (c *Coordinator) notifyQueue() {
+	c.events.publish("", "queue", c.queueStatus())
+	for _, s := range c.slots[c.currentSlot:] {
+		c.events.publish(s.participantTicket, "position", c.queuePosition(s))
+	}
+}
+
+// notifyTurn tells the participant of the current slot to fetch the ceremony once its slot started.
+func (c *Coordinator) notifyTurn() {
+	if c.config.Pipelined {
+		c.notifyTranscriptTurns()
+		return
+	}
+	if c.currentSlot >= len(c.slots) {
+		return
+	}
+	slot := c.slots[c.currentSlot]
+	if slot.notified || slot.start > time.Now().Unix() {
+		return
+	}
+	slot.notified = true
+	c.events.publish(slot.participantTicket, "turn", c.queuePosition(slot))
+}
+
+// runScheduler expires slots and starts turns without waiting for requests of participants.
+func (c *Coordinator) runScheduler() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.mutex.Lock()
+		c.advanceSlots()
+		c.notifyTurn()
+		c.mutex.Unlock()
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Heartbeat tells the coordinator that the participant is still computing its contribution.
+func (c *Coordinator) Heartbeat(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advanceSlots()
+	slot := c.slotByTicket[mux.Vars(req)["ticket"]]
+	if slot == nil || c.slotFinished(slot) {
+		rw.WriteHeader(403)
+		return
+	}
+	slot.lastHeartbeat = time.Now()
+	rw.WriteHeader(200)
+}
+
+// Abort gives up the slot of the participant, s.th. the next participants can start earlier.
+func (c *Coordinator) Abort(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advanceSlots()
+	slot := c.slotByTicket[mux.Vars(req)["ticket"]]
+	if slot == nil || c.slotFinished(slot) || slot.submitted {
+		rw.WriteHeader(403)
+		return
+	}
+	fmt.Printf("Participant no. %v aborted\n", slot.index)
+	slot.released = true
+	c.advanceSlots()
+	c.notifyTurn()
+	rw.WriteHeader(200)
+}
+
+// heartbeatMissed returns whether the participant fetched the ceremony but stopped sending heartbeats.
+func (c *Coordinator) heartbeatMissed(slot *slot) bool {
+	timeout := c.config.Slots.HeartbeatTimeout
+	if timeout <= 0 || slot.submitted || slot.lastHeartbeat.IsZero() {
+		return false
+	}
+	return time.Since(slot.lastHeartbeat) > time.Duration(timeout)*time.Second
+}
+
+// pullForward moves the start of the waiting participants forward once the slot before them
+// ended early. Only participants following the event stream are moved, they are notified
+// immediately, everyone else might still be sleeping until their start time. Reserved slots
+// and everyone behind them keep their time. Mutex has to be held.
+func (c *Coordinator) pullForward() {
+	earliest := time.Now().Unix() + immediateStartDelay
+	for i := c.currentSlot; i < len(c.slots); i++ {
+		s := c.slots[i]
+		if s.released {
+			// Skipped once it is reached
+			continue
+		}
+		if s.reserved || s.start <= earliest || !c.events.listening(s.participantTicket) {
+			return
+		}
+		shift := s.start - earliest
+		s.start -= shift
+		s.deadline -= shift
+		// The next participant can start once this one is done and verified
+		earliest = s.deadline + coordinatorTime
+	}
+}
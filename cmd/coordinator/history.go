@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// historyDir is the default directory accepted ceremonies are published in
+const historyDir = "history"
+
+// logFile is the file in the history directory the signed log entries are appended to, one per line
+const logFile = "log.jsonl"
+
+// recordContribution publishes the accepted ceremony and the record of the contribution.
+// In pipelined mode transcript is the index of the transcript that was contributed to.
+func (c *Coordinator) recordContribution(slot *slot, ceremony *towersofpau.Ceremony, serialized []byte, hash string, timestamp int64, transcript *int) {
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	pubkeys := ceremony.LatestPotPubkeys()
+	if transcript != nil {
+		pubkeys = pubkeys[*transcript : *transcript+1]
+	}
+	c.publishContribution(towersofpau.Contribution{
+		Index:        len(c.history),
+		Slot:         slot.index,
+		Timestamp:    timestamp,
+		Identity:     slot.identity,
+		Transcript:   transcript,
+		PotPubkeys:   pubkeys,
+		CeremonyHash: hash,
+	}, serialized)
+}
+
+// publishContribution appends the contribution to the history and the log and writes it to disk,
+// serialized is the ceremony after the contribution. historyMutex has to be held.
+func (c *Coordinator) publishContribution(contribution towersofpau.Contribution, serialized []byte) {
+	c.history = append(c.history, contribution)
+	c.appendLog(contribution)
+	c.events.publish("", "contribution", contribution)
+	c.currentCeremony = newCachedBytes(serialized)
+	// The last page now contains another contribution and the total of every page changed
+	c.historyPages = make(map[int]*cachedBytes)
+
+	if err := os.WriteFile(c.historyFile(contribution.Index), serialized, 0644); err != nil {
+		fmt.Printf("Unable to write history: %v\n", err)
+		return
+	}
+	record, err := json.Marshal(contribution)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(c.historyDir, fmt.Sprintf("%d.contribution.json", contribution.Index)), record, 0644); err != nil {
+		fmt.Printf("Unable to write history: %v\n", err)
+	}
+}
+
+// appendLog signs the log entry of the contribution, chains it to the previous entry and appends it
+// to the log file. historyMutex has to be held.
+func (c *Coordinator) appendLog(contribution towersofpau.Contribution) {
+	var prevHash string
+	if len(c.log) > 0 {
+		var err error
+		if prevHash, err = c.log[len(c.log)-1].Entry.Hash(); err != nil {
+			fmt.Printf("Unable to hash log entry: %v\n", err)
+			return
+		}
+	}
+	signed, err := towersofpau.SignLogEntry(towersofpau.LogEntry{
+		Ceremony:     c.id,
+		Index:        contribution.Index,
+		PrevHash:     prevHash,
+		Slot:         contribution.Slot,
+		Transcript:   contribution.Transcript,
+		Identity:     contribution.Identity,
+		PotPubkeys:   contribution.PotPubkeys,
+		CeremonyHash: contribution.CeremonyHash,
+		Timestamp:    contribution.Timestamp,
+		Beacon:       contribution.Beacon,
+	}, c.receiptKey)
+	if err != nil {
+		fmt.Printf("Unable to sign log entry: %v\n", err)
+		return
+	}
+	c.log = append(c.log, *signed)
+	c.appendLeaf(&signed.Entry)
+
+	line, err := json.Marshal(signed)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(filepath.Join(c.historyDir, logFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Printf("Unable to write log: %v\n", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		fmt.Printf("Unable to write log: %v\n", err)
+	}
+}
+
+// loadHistory continues the history, log and tree of an earlier run from the log file in the history directory.
+// The log is never rewritten, so a log that can not be continued keeps the coordinator from starting.
+func (c *Coordinator) loadHistory() error {
+	data, err := os.ReadFile(filepath.Join(c.historyDir, logFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+	var log []towersofpau.SignedLogEntry
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		var entry towersofpau.SignedLogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("%v line %v: %v", logFile, i+1, err)
+		}
+		if entry.Entry.Ceremony != c.id {
+			return fmt.Errorf("%v line %v: entry of ceremony %q", logFile, i+1, entry.Entry.Ceremony)
+		}
+		log = append(log, entry)
+	}
+	if err := towersofpau.VerifyLog(log, c.receiptKey.Public().(ed25519.PublicKey)); err != nil {
+		return fmt.Errorf("%v: %v", logFile, err)
+	}
+	last := log[len(log)-1].Entry
+	serialized, err := os.ReadFile(c.historyFile(last.Index))
+	if err != nil {
+		return err
+	}
+	ceremony, err := towersofpau.Deserialize(bytes.NewReader(serialized))
+	if err != nil {
+		return fmt.Errorf("history %v: %v", last.Index, err)
+	}
+	if hash, err := ceremonyHash(ceremony); err != nil || hash != last.CeremonyHash {
+		return fmt.Errorf("history %v does not match the log", last.Index)
+	}
+	if len(ceremony.Transcripts) != len(c.ceremony.Transcripts) {
+		return fmt.Errorf("history %v does not match the initial ceremony", last.Index)
+	}
+
+	for i := range log {
+		entry := &log[i].Entry
+		leaf, err := entry.LeafHash()
+		if err != nil {
+			return err
+		}
+		c.leaves = append(c.leaves, leaf)
+		c.logIndex[entry.CeremonyHash] = entry.Index
+		c.history = append(c.history, towersofpau.Contribution{
+			Index:        entry.Index,
+			Slot:         entry.Slot,
+			Timestamp:    entry.Timestamp,
+			Identity:     entry.Identity,
+			Transcript:   entry.Transcript,
+			PotPubkeys:   entry.PotPubkeys,
+			CeremonyHash: entry.CeremonyHash,
+			Beacon:       entry.Beacon,
+		})
+		if entry.Beacon != nil {
+			c.beacon = entry.Beacon
+		} else if entry.Identity != "" {
+			// Every identity can only contribute once, also across restarts
+			c.contributors[entry.Identity] = true
+		}
+	}
+	c.log = log
+	c.ceremony = ceremony
+	c.currentCeremony = newCachedBytes(serialized)
+	fmt.Printf("Continuing the history of %v after %v contributions\n", c.id, len(log))
+	return nil
+}
+
+func (c *Coordinator) historyFile(index int) string {
+	return filepath.Join(c.historyDir, fmt.Sprintf("%d.json", index))
+}
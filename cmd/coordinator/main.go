@@ -1,44 +1,109 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 
-	"github.com/dknopik/towersofpau"
 	"github.com/gorilla/mux"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		panic("invalid amount of args, need 2")
+	configPath := flag.String("config", "", "path to the coordinator config file")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		panic("invalid amount of args, need path to initial ceremony")
 	}
-	path := os.Args[1]
-	file, err := os.Open(path)
+	config := DefaultConfig()
+	if *configPath != "" {
+		var err error
+		config, err = LoadConfig(*configPath)
+		if err != nil {
+			log.Fatal("unable to load config ", err.Error())
+		}
+	}
+	key, err := receiptKey(config)
 	if err != nil {
-		log.Fatal("unable to open")
+		log.Fatal("invalid receipt key ", err.Error())
 	}
-	fmt.Println("Reading initial ceremony")
-	ceremony, err := towersofpau.Deserialize(file)
+	fmt.Printf("Signing receipts with key 0x%x\n", key.Public())
+	fmt.Println("Starting coordinator")
+	coordinator, err := startCeremony(mainCeremony, flag.Arg(0), config, key)
 	if err != nil {
-		log.Fatal("unable to decode", err.Error())
+		log.Fatal("unable to start coordinator ", err.Error())
 	}
-	err = os.Mkdir("history", os.ModePerm)
-	if err != nil && !os.IsExist(err) {
-		log.Fatal("unable to create history dir", err.Error())
+	ceremonies, err := startCeremonies(config, key)
+	if err != nil {
+		log.Fatal("unable to start ceremonies ", err.Error())
 	}
-	fmt.Println("Starting coordinator")
-	coordinator := NewCoordinator(ceremony)
+	ceremonies = append([]*Coordinator{coordinator}, ceremonies...)
 	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/ceremonies", Ceremonies(ceremonies)).
+		Methods("GET")
+	for _, c := range ceremonies {
+		registerRoutes(router.PathPrefix(ceremonyPath(c.id)).Subrouter(), c)
+	}
+	// The main ceremony is also served at the root
+	registerRoutes(router, coordinator)
+	err = http.ListenAndServe(":2016", router)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// registerRoutes adds the endpoints of the ceremony run by coordinator to router.
+func registerRoutes(router *mux.Router, coordinator *Coordinator) {
 	router.HandleFunc("/participation", coordinator.RegisterParticipant).
 		Methods("POST")
+	router.HandleFunc("/participation/challenge", coordinator.IssueChallenge).
+		Methods("GET")
+	router.HandleFunc("/participation/signin", coordinator.IssueSignIn).
+		Methods("GET")
 	router.HandleFunc("/participation/{ticket}", coordinator.RetrieveParticipant).
 		Methods("GET")
+	router.HandleFunc("/participation/{ticket}/events", coordinator.ParticipantEvents).
+		Methods("GET")
+	router.HandleFunc("/participation/{ticket}/calendar.ics", coordinator.Calendar).
+		Methods("GET")
+	router.HandleFunc("/participation/{ticket}/heartbeat", coordinator.Heartbeat).
+		Methods("POST")
+	router.HandleFunc("/participation/{ticket}/abort", coordinator.Abort).
+		Methods("POST")
 	router.HandleFunc("/participation/{ticket}", coordinator.SubmitCeremony).
 		Methods("POST")
-	err = http.ListenAndServe(":2016", router)
-	if err != nil {
-		log.Fatal(err)
-	}
+	router.HandleFunc("/participation/{ticket}/transcripts/{transcript:[0-9]+}", coordinator.SubmitTranscript).
+		Methods("POST")
+	router.HandleFunc("/ceremony/current", coordinator.CurrentCeremony).
+		Methods("GET")
+	router.HandleFunc("/history", coordinator.History).
+		Methods("GET")
+	router.HandleFunc("/history/{index:[0-9]+}", coordinator.HistoryEntry).
+		Methods("GET")
+	router.HandleFunc("/log", coordinator.Log).
+		Methods("GET")
+	router.HandleFunc("/tree/head", coordinator.TreeHead).
+		Methods("GET")
+	router.HandleFunc("/tree/inclusion", coordinator.InclusionProof).
+		Methods("GET")
+	router.HandleFunc("/tree/consistency", coordinator.ConsistencyProof).
+		Methods("GET")
+	router.HandleFunc("/status", coordinator.Status).
+		Methods("GET")
+	router.HandleFunc("/events", coordinator.Events).
+		Methods("GET")
+	router.HandleFunc("/admin/policy", coordinator.admin(coordinator.GetPolicy)).
+		Methods("GET")
+	router.HandleFunc("/admin/open", coordinator.admin(coordinator.OpenRegistration)).
+		Methods("POST")
+	router.HandleFunc("/admin/close", coordinator.admin(coordinator.CloseRegistration)).
+		Methods("POST")
+	router.HandleFunc("/admin/finalize", coordinator.admin(coordinator.Finalize)).
+		Methods("POST")
+	router.HandleFunc("/admin/{list:allowlist|priority|bans}/{entry}", coordinator.admin(coordinator.AddPolicyEntry)).
+		Methods("PUT")
+	router.HandleFunc("/admin/{list:allowlist|priority|bans}/{entry}", coordinator.admin(coordinator.RemovePolicyEntry)).
+		Methods("DELETE")
+	router.HandleFunc("/", coordinator.Index).
+		Methods("GET")
 }
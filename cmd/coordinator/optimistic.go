@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// In optimistic mode a submission becomes the new state as soon as the cheap checks passed and the
+// next participant can start while the pairing check is still running. If the pairing check fails,
+// the state is rolled back and every participant that contributed on top of it is re-queued.
+// Contributions are published and receipted in order, once the pairing checks of all previous
+// contributions passed.
+
+// pendingContribution is a submission that was handed on before its pairing check finished.
+type pendingContribution struct {
+	slot     *slot
+	prev     *towersofpau.Ceremony
+	ceremony *towersofpau.Ceremony
+	// previous is the pending contribution prev is the result of, nil if prev is verified
+	previous *pendingContribution
+	// invalid is set if this or a previous contribution failed the pairing check, guarded by mutex
+	invalid bool
+	// done is closed once the contribution was accepted or rejected
+	done chan struct{}
+}
+
+// lastPending returns the contribution the current state is the result of, nil if it is verified.
+// Mutex has to be held.
+func (c *Coordinator) lastPending() *pendingContribution {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	return c.pending[len(c.pending)-1]
+}
+
+// submitOptimistic hands on the submission of slot after the cheap checks and answers once it is verified.
+func (c *Coordinator) submitOptimistic(rw http.ResponseWriter, slot *slot, body []byte, newCeremony *towersofpau.Ceremony) {
+	var identity string
+	if c.config.Identity.RequireBLSSignatures {
+		identity = slot.identity
+	}
+	receipt := towersofpau.Receipt{
+		SlotIndex:    slot.index,
+		Identity:     slot.receiptIdentity(),
+		PotPubkeys:   newCeremony.LatestPotPubkeys(),
+		CeremonyHash: submissionHash(newCeremony, body),
+	}
+
+	p, err := c.handOn(slot, newCeremony, identity)
+	if err != nil {
+		c.finishSlot()
+		fmt.Printf("Submission from %v rejected: %v\n", slot.index, err)
+		c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Reason: err.Error()})
+		receipt.Reason = err.Error()
+		c.writeReceipt(rw, 400, receipt)
+		return
+	}
+	fmt.Printf("Handing on submission from %v, pairing check pending\n", slot.index)
+	c.finishSlot()
+	defer close(p.done)
+
+	start := time.Now()
+	verifyErr := c.verify(p.prev, newCeremony, identity, true)
+	fmt.Printf("Pairing check of submission from %v took %v\n", slot.index, time.Since(start))
+	if p.previous != nil {
+		<-p.previous.done
+	}
+	if err := c.settle(p, verifyErr); err != nil {
+		fmt.Printf("Submission from %v rejected: %v\n", slot.index, err)
+		c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Reason: err.Error()})
+		receipt.Reason = err.Error()
+		c.writeReceipt(rw, 400, receipt)
+		return
+	}
+	fmt.Printf("Submission from %v verified\n", slot.index)
+	c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Accepted: true})
+	buf := new(bytes.Buffer)
+	if err := towersofpau.Serialize(buf, newCeremony); err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	if receipt.CeremonyHash, err = ceremonyHash(newCeremony); err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	receipt.Timestamp = time.Now().Unix()
+	receipt.Accepted = true
+	c.writeReceipt(rw, 200, receipt)
+	c.recordContribution(slot, newCeremony, buf.Bytes(), receipt.CeremonyHash, receipt.Timestamp, nil)
+}
+
+// handOn runs the cheap checks on the submission of slot and makes it the current state if they pass.
+func (c *Coordinator) handOn(slot *slot, newCeremony *towersofpau.Ceremony, identity string) (*pendingContribution, error) {
+	c.ceremonyMutex.Lock()
+	defer c.ceremonyMutex.Unlock()
+	start := time.Now()
+	err := checkLocally(c.ceremony, newCeremony, identity)
+	if err == nil {
+		// The pubkeys stay used even if the contribution is rolled back
+		err = c.usePubkeys(newCeremony.LatestPotPubkeys())
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.recordVerificationTime(time.Since(start))
+	if err != nil {
+		if slot.basedOn != nil && slot.basedOn.invalid {
+			// The state the participant contributed to was rolled back in the meantime
+			c.requeue(slot)
+			return nil, errors.New("contributed on top of a rolled back contribution, re-queued")
+		}
+		c.strike(slot, "submitted an invalid ceremony")
+		return nil, err
+	}
+	p := &pendingContribution{
+		slot:     slot,
+		prev:     c.ceremony,
+		ceremony: newCeremony,
+		previous: c.lastPending(),
+		done:     make(chan struct{}),
+	}
+	c.pending = append(c.pending, p)
+	c.ceremony = newCeremony
+	return p, nil
+}
+
+// settle accepts or rejects the pending contribution p once its pairing check and the ones of all previous
+// pending contributions are done.
+func (c *Coordinator) settle(p *pendingContribution, verifyErr error) error {
+	c.ceremonyMutex.Lock()
+	defer c.ceremonyMutex.Unlock()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch {
+	case p.invalid:
+		c.requeue(p.slot)
+		return errors.New("contributed on top of a rejected contribution, re-queued")
+	case verifyErr != nil:
+		c.strike(p.slot, "submitted an invalid ceremony")
+		c.rollback(p)
+		return verifyErr
+	}
+	// All previous pending contributions are done, so p is the first one
+	c.pending = c.pending[1:]
+	p.slot.contributed = true
+	return nil
+}
+
+// rollback restores the state before the invalid contribution p and invalidates every contribution built on it.
+// The participant working on an invalidated state is re-queued. Mutex and ceremonyMutex have to be held.
+func (c *Coordinator) rollback(p *pendingContribution) {
+	fmt.Printf("Rolling back to the state before the submission from %v\n", p.slot.index)
+	c.ceremony = p.prev
+	for i, pending := range c.pending {
+		if pending == p {
+			for _, invalid := range c.pending[i:] {
+				invalid.invalid = true
+			}
+			c.pending = c.pending[:i]
+			break
+		}
+	}
+	if c.currentSlot < len(c.slots) {
+		current := c.slots[c.currentSlot]
+		if !current.fetched.IsZero() && !current.submitted && current.basedOn != nil && current.basedOn.invalid {
+			current.released = true
+			c.advanceSlots()
+			c.requeue(current)
+			c.notifyTurn()
+		}
+	}
+}
+
+// requeue gives the participant of old a new slot ahead of the public queue, after its contribution
+// was built on an invalid contribution. Mutex has to be held.
+func (c *Coordinator) requeue(old *slot) {
+	length := old.deadline - old.start
+	position, start, _ := c.findPrioritySlot(length)
+	s := &slot{
+		start:             start,
+		deadline:          start + length,
+		participantTicket: old.participantTicket,
+		priority:          true,
+		ip:                old.ip,
+		identity:          old.identity,
+	}
+	c.insertSlot(position, s)
+	c.slotByTicket[s.participantTicket] = s
+	if s.identity != "" {
+		c.slotByIdentity[s.identity] = s
+	}
+	fmt.Printf("Re-queued participant no. %v as no. %v\n", old.index, s.index)
+	c.notifyQueue()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// testCeremony returns a small initial ceremony of two transcripts.
+func testCeremony() *towersofpau.Ceremony {
+	ceremony := new(towersofpau.Ceremony)
+	for _, size := range [][2]int{{16, 4}, {8, 2}} {
+		transcript := &towersofpau.Transcript{
+			NumG1Powers: size[0],
+			NumG2Powers: size[1],
+			Witness: &towersofpau.Witness{
+				RunningProducts: []*blst.P1{blst.P1Generator()},
+				PotPubkeys:      blst.P2Affines{*blst.P2Generator().ToAffine()},
+			},
+		}
+		for i := 0; i < size[0]; i++ {
+			transcript.PowersOfTau.G1Powers = append(transcript.PowersOfTau.G1Powers, blst.P1Generator())
+		}
+		for i := 0; i < size[1]; i++ {
+			transcript.PowersOfTau.G2Powers = append(transcript.PowersOfTau.G2Powers, blst.P2Generator())
+		}
+		ceremony.Transcripts = append(ceremony.Transcripts, transcript)
+	}
+	return ceremony
+}
+
+// contribute returns prev with a new contribution, a broken contribution passes the cheap checks but not the pairing check.
+func contribute(t *testing.T, prev *towersofpau.Ceremony, broken bool) *towersofpau.Ceremony {
+	next := prev.Copy()
+	if err := towersofpau.UpdateTranscript(next, ""); err != nil {
+		t.Fatal(err)
+	}
+	if broken {
+		powers := next.Transcripts[0].PowersOfTau.G1Powers
+		powers[len(powers)-1] = powers[len(powers)-2]
+	}
+	return next
+}
+
+// newOptimisticCoordinator starts an optimistic coordinator with a running slot for each of the IPs.
+func newOptimisticCoordinator(t *testing.T, initial *towersofpau.Ceremony, ips ...string) (*Coordinator, []*slot) {
+	config := DefaultConfig()
+	config.Optimistic = true
+	config.HistoryDir = t.TempDir()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCoordinator(mainCeremony, initial, config, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	var slots []*slot
+	for i, ip := range ips {
+		s := &slot{
+			start:             now - 1,
+			deadline:          now + 600,
+			participantTicket: getTicket(),
+			ip:                ip,
+		}
+		c.insertSlot(i, s)
+		c.slotByTicket[s.participantTicket] = s
+		slots = append(slots, s)
+	}
+	return c, slots
+}
+
+func TestOptimisticRollback(t *testing.T) {
+	initial := testCeremony()
+	c, slots := newOptimisticCoordinator(t, initial, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	// fetch lets the participant of the current slot fetch the current state, like RetrieveParticipant does
+	fetch := func(s *slot) {
+		s.basedOn = c.lastPending()
+		s.fetched = time.Now()
+		s.lastHeartbeat = s.fetched
+	}
+
+	// The first participant submits a contribution that fails the pairing check, the second one
+	// contributes on top of it and the third one is still working on the result
+	fetch(slots[0])
+	bad := contribute(t, initial, true)
+	first, err := c.handOn(slots[0], bad, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.finishSlot()
+	fetch(slots[1])
+	second, err := c.handOn(slots[1], contribute(t, bad, false), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.finishSlot()
+	fetch(slots[2])
+	if slots[2].basedOn != second || len(c.pending) != 2 {
+		t.Fatal("submissions were not handed on")
+	}
+
+	verifyErr := c.verify(first.prev, bad, "", true)
+	if verifyErr == nil {
+		t.Fatal("broken contribution passed the pairing check")
+	}
+	if err := c.settle(first, verifyErr); err != verifyErr {
+		t.Fatalf("invalid contribution settled with %v", err)
+	}
+	close(first.done)
+	if c.ceremony != initial || len(c.pending) != 0 {
+		t.Fatal("state was not rolled back")
+	}
+	if !first.invalid || !second.invalid {
+		t.Fatal("dependent contribution was not invalidated")
+	}
+	if c.policy.strikes["192.0.2.1"] != 1 {
+		t.Fatal("invalid contribution was not counted against the participant")
+	}
+	// The participant working on the rolled back state is released and re-queued right away
+	if !slots[2].released {
+		t.Fatal("participant working on the rolled back state was not released")
+	}
+	requeued := c.slotByTicket[slots[2].participantTicket]
+	if requeued == slots[2] || !requeued.priority || requeued.index < c.currentSlot {
+		t.Fatalf("participant was not re-queued: %+v", requeued)
+	}
+
+	// The contribution on top of the invalid one is rejected once its own check is done and re-queued
+	if err := c.settle(second, c.verify(second.prev, second.ceremony, "", true)); err == nil {
+		t.Fatal("contribution on top of an invalid one accepted")
+	}
+	close(second.done)
+	requeued = c.slotByTicket[slots[1].participantTicket]
+	if requeued == slots[1] || !requeued.priority || requeued.index < c.currentSlot {
+		t.Fatalf("participant was not re-queued: %+v", requeued)
+	}
+	if c.policy.strikes["192.0.2.2"] != 0 {
+		t.Fatal("re-queued participant got a strike")
+	}
+
+	// The pot pubkeys of the rolled back contribution stay used, also after a restart
+	if err := c.usePubkeys(bad.LatestPotPubkeys()); err == nil {
+		t.Fatal("pubkeys of the rolled back contribution used again")
+	}
+	pubkeys, err := loadPubkeys(c.historyDir, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pubkeys.Add(bad.LatestPotPubkeys()); err == nil {
+		t.Fatal("pubkeys of the rolled back contribution were not saved")
+	}
+
+	// A valid contribution on top of the restored state is accepted
+	current := c.slots[c.currentSlot]
+	fetch(current)
+	good := contribute(t, initial, false)
+	p, err := c.handOn(current, good, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.settle(p, c.verify(p.prev, good, "", true)); err != nil {
+		t.Fatal(err)
+	}
+	if c.ceremony != good || len(c.pending) != 0 || !current.contributed {
+		t.Fatal("valid contribution was not accepted")
+	}
+}
+
+func TestOptimisticRequeueOnResubmission(t *testing.T) {
+	initial := testCeremony()
+	c, slots := newOptimisticCoordinator(t, initial, "192.0.2.1", "192.0.2.2")
+	first, second := slots[0], slots[1]
+
+	bad := contribute(t, initial, true)
+	p, err := c.handOn(first, bad, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.finishSlot()
+	second.basedOn = p
+	second.fetched = time.Now()
+	// The second participant already submitted when the rollback happens, so it is not released
+	second.submitted = true
+	if err := c.settle(p, c.verify(p.prev, bad, "", true)); err == nil {
+		t.Fatal("invalid contribution accepted")
+	}
+	if second.released {
+		t.Fatal("participant that already submitted was released")
+	}
+	// Its submission no longer continues the current state and is re-queued instead of struck
+	if _, err := c.handOn(second, contribute(t, bad, false), ""); err == nil {
+		t.Fatal("contribution on top of a rolled back state handed on")
+	}
+	requeued := c.slotByTicket[second.participantTicket]
+	if requeued == second || !requeued.priority || c.policy.strikes["192.0.2.2"] != 0 {
+		t.Fatal("participant was not re-queued")
+	}
+}
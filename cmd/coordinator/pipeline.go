@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/gorilla/mux"
+)
+
+// In pipelined mode the participants contribute to the transcripts one after another. A participant
+// can work on a transcript as soon as the participant before it submitted that transcript, s.th.
+// several participants work on different transcripts at the same time. The ceremony is replaced
+// instead of modified on every accepted transcript and guarded by mutex, ceremonyMutex orders
+// the accepted transcripts in the history.
+
+// predecessor returns the closest slot before slot that still contributes, mutex has to be held.
+func (c *Coordinator) predecessor(slot *slot) *slot {
+	for i := slot.index - 1; i >= c.currentSlot; i-- {
+		if !c.slotFinished(c.slots[i]) {
+			return c.slots[i]
+		}
+	}
+	return nil
+}
+
+// nextTranscript returns the index of the transcript the participant can contribute to now.
+// Mutex has to be held.
+func (c *Coordinator) nextTranscript(slot *slot) (int, bool) {
+	if c.slotFinished(slot) || slot.submitted || slot.progress >= len(c.ceremony.Transcripts) {
+		return 0, false
+	}
+	if slot.progress == 0 && slot.reserved && slot.start > time.Now().Unix() {
+		return 0, false
+	}
+	if p := c.predecessor(slot); p != nil && p.progress <= slot.progress {
+		return 0, false
+	}
+	return slot.progress, true
+}
+
+// releaseStalledSlots releases the running slots behind the current slot whose participant missed
+// the deadline or stopped sending heartbeats. Mutex has to be held.
+func (c *Coordinator) releaseStalledSlots() bool {
+	var released bool
+	now := time.Now().Unix()
+	for _, s := range c.slots[c.currentSlot:] {
+		if c.slotFinished(s) || s.submitted {
+			continue
+		}
+		if c.heartbeatMissed(s) {
+			c.strike(s, "stopped sending heartbeats")
+		} else if s.deadline < now {
+			c.strike(s, "missed its slot")
+		} else {
+			continue
+		}
+		fmt.Printf("Releasing slot of participant no. %v\n", s.index)
+		s.released = true
+		released = true
+	}
+	return released
+}
+
+// notifyTranscriptTurns tells every participant that can start on its first transcript to fetch it.
+// Participants following the event stream start right away, even before their scheduled start.
+// Mutex has to be held.
+func (c *Coordinator) notifyTranscriptTurns() {
+	now := time.Now().Unix()
+	for _, s := range c.slots[c.currentSlot:] {
+		if c.slotFinished(s) {
+			continue
+		}
+		if _, ok := c.nextTranscript(s); !ok {
+			if s.progress == 0 {
+				// Everyone behind is waiting for this participant
+				return
+			}
+			continue
+		}
+		if s.notified {
+			continue
+		}
+		if s.start > now {
+			if s.reserved || !c.events.listening(s.participantTicket) {
+				return
+			}
+			shift := s.start - now
+			s.start -= shift
+			s.deadline -= shift
+		}
+		s.notified = true
+		c.events.publish(s.participantTicket, "turn", c.queuePosition(s))
+	}
+}
+
+// retrieveTranscript answers GET /participation/{ticket} in pipelined mode with the next transcript
+// of the participant, if it is available yet. Mutex has to be held.
+func (c *Coordinator) retrieveTranscript(rw http.ResponseWriter, slot *slot, response towersofpau.FetchResponse) {
+	if c.slotFinished(slot) {
+		rw.WriteHeader(403)
+		return
+	}
+	if index, ok := c.nextTranscript(slot); ok {
+		jsonceremony, err := towersofpau.SerializeJSONCeremony(c.ceremony.TranscriptCeremony(index))
+		if err != nil {
+			rw.WriteHeader(500)
+			return
+		}
+		fmt.Printf("Participant no. %v retrieved transcript %v\n", slot.index, index)
+		response.Ceremony = &jsonceremony
+		response.Transcript = &index
+		if slot.fetched.IsZero() {
+			slot.fetched = time.Now()
+			slot.lastHeartbeat = slot.fetched
+		}
+	}
+	response.TranscriptCount = len(c.ceremony.Transcripts)
+	response.QueueInfo = c.queueInfo(slot)
+	resp, err := json.Marshal(response)
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Write(resp)
+}
+
+// SubmitTranscript accepts the contribution to a single transcript in pipelined mode.
+// The body is a ceremony consisting only of the updated transcript.
+func (c *Coordinator) SubmitTranscript(rw http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	index, err := strconv.Atoi(vars["transcript"])
+	if err != nil {
+		rw.WriteHeader(400)
+		return
+	}
+	c.mutex.Lock()
+	slot := c.slotByTicket[vars["ticket"]]
+	if !c.config.Pipelined || slot == nil {
+		c.mutex.Unlock()
+		rw.WriteHeader(403)
+		return
+	}
+	if next, ok := c.nextTranscript(slot); !ok || next != index {
+		c.mutex.Unlock()
+		rw.WriteHeader(403)
+		return
+	}
+	fmt.Printf("Received transcript %v from %v\n", index, slot.index)
+	slot.submitted = true
+	prevCeremony := c.ceremony.TranscriptCeremony(index)
+	c.mutex.Unlock()
+	accepted := false
+	defer func() {
+		// The slot can not stay submitted if the transcript was not accepted, not even if we panicked
+		if !accepted {
+			c.mutex.Lock()
+			slot.submitted = false
+			c.mutex.Unlock()
+		}
+	}()
+
+	uploadStart := time.Now()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		rw.WriteHeader(400)
+		return
+	}
+	c.mutex.Lock()
+	c.recordThroughput(len(body), time.Since(uploadStart))
+	c.mutex.Unlock()
+
+	receipt := towersofpau.Receipt{
+		SlotIndex:  slot.index,
+		Transcript: &index,
+		Identity:   slot.receiptIdentity(),
+	}
+	newCeremony, err := towersofpau.Deserialize(bytes.NewReader(body))
+	receipt.CeremonyHash = submissionHash(newCeremony, body)
+	if err == nil && len(newCeremony.Transcripts) != 1 {
+		err = fmt.Errorf("expected a single transcript, got %v", len(newCeremony.Transcripts))
+	}
+	if err == nil {
+		receipt.PotPubkeys = newCeremony.LatestPotPubkeys()
+		var identity string
+		if c.config.Identity.RequireBLSSignatures {
+			identity = slot.identity
+		}
+		start := time.Now()
+		// Only this participant can submit the transcript right now, so prevCeremony can not change
+		err = c.verify(prevCeremony, newCeremony, identity, false)
+		if err == nil {
+			err = c.usePubkeys(receipt.PotPubkeys)
+		}
+		fmt.Printf("Verified transcript %v from %v in %v\n", index, slot.index, time.Since(start))
+	}
+	if err != nil {
+		fmt.Printf("Transcript %v from %v rejected: %v\n", index, slot.index, err)
+		c.mutex.Lock()
+		slot.submitted = false
+		slot.released = true
+		c.strike(slot, "submitted an invalid transcript")
+		c.advanceSlots()
+		c.notifyTurn()
+		c.mutex.Unlock()
+		c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Reason: err.Error()})
+		receipt.Reason = err.Error()
+		c.writeReceipt(rw, 400, receipt)
+		return
+	}
+
+	// Accepted transcripts are published one after another, s.th. every history entry contains all previous ones
+	c.ceremonyMutex.Lock()
+	defer c.ceremonyMutex.Unlock()
+	c.mutex.Lock()
+	c.ceremony = c.ceremony.WithTranscript(index, newCeremony.Transcripts[0])
+	ceremony := c.ceremony
+	slot.submitted = false
+	accepted = true
+	slot.progress++
+	slot.lastHeartbeat = time.Now()
+	if slot.progress == len(ceremony.Transcripts) {
+		slot.contributed = true
+	}
+	c.mutex.Unlock()
+	c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Accepted: true})
+
+	buf := new(bytes.Buffer)
+	if err := towersofpau.Serialize(buf, ceremony); err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	if receipt.CeremonyHash, err = ceremonyHash(ceremony); err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	receipt.Timestamp = time.Now().Unix()
+	receipt.Accepted = true
+	c.writeReceipt(rw, 200, receipt)
+	c.recordContribution(slot, ceremony, buf.Bytes(), receipt.CeremonyHash, receipt.Timestamp, &index)
+
+	c.mutex.Lock()
+	c.advanceSlots()
+	c.notifyTurn()
+	c.mutex.Unlock()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errBanned    = errors.New("participant is banned")
+	errClosed    = errors.New("registration is closed")
+	errFinalized = errors.New("ceremony is finalized")
+)
+
+// policy decides who may register and who is scheduled first, it is guarded by the mutex of the coordinator.
+type policy struct {
+	closed    bool
+	allowlist map[string]bool
+	priority  map[string]bool
+	bans      map[string]bool
+	strikes   map[string]int
+	// banAfterStrikes is the number of strikes that bans a participant, 0 disables automatic bans
+	banAfterStrikes int
+}
+
+func newPolicy(config PolicyConfig) *policy {
+	return &policy{
+		closed:          config.Closed,
+		allowlist:       newSet(config.Allowlist),
+		priority:        newSet(config.Priority),
+		bans:            newSet(config.Bans),
+		strikes:         make(map[string]int),
+		banAfterStrikes: config.BanAfterStrikes,
+	}
+}
+
+func newSet(entries []string) map[string]bool {
+	set := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		set[entry] = true
+	}
+	return set
+}
+
+// contains returns whether any of the non-empty keys is in set.
+func contains(set map[string]bool, keys ...string) bool {
+	for _, key := range keys {
+		if key != "" && set[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkPolicy rejects banned participants and, in a closed phase, participants not on the allowlist.
+// Nobody can register after the ceremony was finalized.
+// It returns whether the participant is scheduled in the priority lane. Mutex has to be held.
+func (c *Coordinator) checkPolicy(ip, identity, invite string) (bool, error) {
+	if c.beacon != nil {
+		return false, errFinalized
+	}
+	p := c.policy
+	if contains(p.bans, ip, identity) {
+		return false, errBanned
+	}
+	priority := contains(p.priority, identity, invite)
+	if p.closed && !priority && !contains(p.allowlist, identity, invite) {
+		return false, errClosed
+	}
+	return priority, nil
+}
+
+// strike counts a missed slot or invalid submission against the participant, anonymous participants
+// are tracked by their IP. Participants are banned once they reach the configured number of strikes.
+// Mutex has to be held.
+func (c *Coordinator) strike(slot *slot, reason string) {
+	key := slot.identity
+	if key == "" {
+		key = slot.ip
+	}
+	p := c.policy
+	p.strikes[key]++
+	fmt.Printf("Participant no. %v (%v) %v, strike %v\n", slot.index, key, reason, p.strikes[key])
+	if p.banAfterStrikes > 0 && p.strikes[key] >= p.banAfterStrikes && !p.bans[key] {
+		fmt.Printf("Banning %v after %v strikes\n", key, p.strikes[key])
+		p.bans[key] = true
+	}
+}
+
+// rejectSubmission counts an invalid submission against the participant and finishes the slot.
+func (c *Coordinator) rejectSubmission(slot *slot) {
+	c.mutex.Lock()
+	c.strike(slot, "submitted an invalid ceremony")
+	c.mutex.Unlock()
+	c.finishSlot()
+}
+
+// admin only passes requests on to handler that carry the admin token.
+func (c *Coordinator) admin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if c.config.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.config.AdminToken)) != 1 {
+			rw.WriteHeader(403)
+			return
+		}
+		handler(rw, req)
+	}
+}
+
+// GetPolicy returns the current participant policy.
+func (c *Coordinator) GetPolicy(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writePolicy(rw)
+}
+
+// OpenRegistration lets everyone who is not banned register.
+func (c *Coordinator) OpenRegistration(rw http.ResponseWriter, req *http.Request) {
+	c.setClosed(rw, false)
+}
+
+// CloseRegistration only lets allowlisted and prioritized participants register.
+func (c *Coordinator) CloseRegistration(rw http.ResponseWriter, req *http.Request) {
+	c.setClosed(rw, true)
+}
+
+func (c *Coordinator) setClosed(rw http.ResponseWriter, closed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.policy.closed = closed
+	fmt.Printf("Registration closed: %v\n", closed)
+	c.events.publish("", "queue", c.queueStatus())
+	c.writePolicy(rw)
+}
+
+// AddPolicyEntry adds an entry to the allowlist, the priority lane or the ban list.
+func (c *Coordinator) AddPolicyEntry(rw http.ResponseWriter, req *http.Request) {
+	c.updatePolicyEntry(rw, req, true)
+}
+
+// RemovePolicyEntry removes an entry from the allowlist, the priority lane or the ban list.
+// Removing a ban also forgives the strikes of the participant.
+func (c *Coordinator) RemovePolicyEntry(rw http.ResponseWriter, req *http.Request) {
+	c.updatePolicyEntry(rw, req, false)
+}
+
+func (c *Coordinator) updatePolicyEntry(rw http.ResponseWriter, req *http.Request, add bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	vars := mux.Vars(req)
+	list, entry := vars["list"], vars["entry"]
+	var set map[string]bool
+	switch list {
+	case "allowlist":
+		set = c.policy.allowlist
+	case "priority":
+		set = c.policy.priority
+	case "bans":
+		set = c.policy.bans
+	default:
+		rw.WriteHeader(404)
+		return
+	}
+	if add {
+		set[entry] = true
+		fmt.Printf("Added %v to %v\n", entry, list)
+	} else {
+		delete(set, entry)
+		if list == "bans" {
+			delete(c.policy.strikes, entry)
+		}
+		fmt.Printf("Removed %v from %v\n", entry, list)
+	}
+	c.writePolicy(rw)
+}
+
+// writePolicy responds with the current policy, mutex has to be held.
+func (c *Coordinator) writePolicy(rw http.ResponseWriter) {
+	resp, err := json.Marshal(towersofpau.Policy{
+		Closed:    c.policy.closed,
+		Allowlist: sortedKeys(c.policy.allowlist),
+		Priority:  sortedKeys(c.policy.priority),
+		Bans:      sortedKeys(c.policy.bans),
+		Strikes:   c.policy.strikes,
+	})
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(resp)
+}
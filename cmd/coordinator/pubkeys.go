@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// pubkeyFile is the file in the history directory the pot pubkeys of accepted contributions are appended to.
+// Unlike the history it is kept when the coordinator restarts, s.th. secrets of earlier runs can not be reused.
+const pubkeyFile = "pubkeys.txt"
+
+// loadPubkeys creates the index of used pot pubkeys from the initial ceremony and the pubkey file.
+func loadPubkeys(historyDir string, ceremony *towersofpau.Ceremony) (*towersofpau.PubkeyIndex, error) {
+	index := towersofpau.NewPubkeyIndex(ceremony)
+	data, err := os.ReadFile(filepath.Join(historyDir, pubkeyFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, pubkey := range strings.Fields(string(data)) {
+		// Pubkeys of the initial ceremony are already in the index
+		index.Add([]string{pubkey})
+	}
+	return index, nil
+}
+
+// usePubkeys rejects a contribution with pot pubkeys that were used before and records its pubkeys otherwise.
+func (c *Coordinator) usePubkeys(pubkeys []string) error {
+	if err := c.pubkeys.Add(pubkeys); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(c.historyDir, pubkeyFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Printf("Unable to write pubkeys: %v\n", err)
+		return nil
+	}
+	defer file.Close()
+	if _, err := file.WriteString(strings.Join(pubkeys, "\n") + "\n"); err != nil {
+		fmt.Printf("Unable to write pubkeys: %v\n", err)
+	}
+	return nil
+}
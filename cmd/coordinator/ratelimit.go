@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Minute
+
+// tokenBucket refills with rate tokens per second up to burst tokens.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  float64
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		tokens: float64(burst),
+		last:   now,
+		rate:   rate,
+		burst:  float64(burst),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take removes a token from the bucket, if no token is available
+// it returns the time until the next token is available.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+type rateLimiter struct {
+	mutex     sync.Mutex
+	config    RateLimitConfig
+	global    *tokenBucket
+	perIP     map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	now := time.Now()
+	limiter := &rateLimiter{
+		config:    config,
+		perIP:     make(map[string]*tokenBucket),
+		lastPrune: now,
+	}
+	if config.GlobalRate > 0 {
+		limiter.global = newTokenBucket(config.GlobalRate, config.GlobalBurst, now)
+	}
+	return limiter
+}
+
+// allow checks whether ip may register now, otherwise it returns how long the client should wait.
+func (l *rateLimiter) allow(ip string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	l.prune(now)
+
+	var bucket *tokenBucket
+	if l.config.PerIPRate > 0 {
+		bucket = l.perIP[ip]
+		if bucket == nil {
+			bucket = newTokenBucket(l.config.PerIPRate, l.config.PerIPBurst, now)
+			l.perIP[ip] = bucket
+		}
+		// Only check here, the token is taken once the global limit passed as well
+		bucket.refill(now)
+		if bucket.tokens < 1 {
+			return bucket.take(now)
+		}
+	}
+	if l.global != nil {
+		if ok, wait := l.global.take(now); !ok {
+			return false, wait
+		}
+	}
+	if bucket != nil {
+		bucket.take(now)
+	}
+	return true, 0
+}
+
+// prune drops the buckets of IPs that did not register for a while.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	for ip, bucket := range l.perIP {
+		if bucket.full(now) {
+			delete(l.perIP, ip)
+		}
+	}
+	l.lastPrune = now
+}
+
+// parseProxies parses the trusted proxies, given as IP addresses or CIDR ranges.
+func parseProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// clientIP returns the IP address the request originated from. Behind trusted proxies it is the
+// rightmost address of X-Forwarded-For that was not added by one of them, everything left of it
+// was sent by the client and can not be trusted.
+func clientIP(req *http.Request, proxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if !trustedProxy(host, proxies) {
+		return host
+	}
+	var forwarded []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(ip) == nil {
+			// Not added by a proxy, the last proxy is the best we know
+			return host
+		}
+		host = ip
+		if !trustedProxy(ip, proxies) {
+			return ip
+		}
+	}
+	return host
+}
+
+func trustedProxy(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// tooManyRequests rejects a request with a 429 and tells the client when to try again.
+func tooManyRequests(rw http.ResponseWriter, retryAfter time.Duration, reason string) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	rw.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(rw, reason, http.StatusTooManyRequests)
+}
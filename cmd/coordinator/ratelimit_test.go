@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bucket := newTokenBucket(0.5, 2, now)
+	for i := 0; i < 2; i++ {
+		if ok, _ := bucket.take(now); !ok {
+			t.Fatalf("token %v of the burst rejected", i)
+		}
+	}
+	if ok, wait := bucket.take(now); ok || wait != 2*time.Second {
+		t.Fatalf("empty bucket returned %v, %v", ok, wait)
+	}
+	if ok, wait := bucket.take(now.Add(time.Second)); ok || wait != time.Second {
+		t.Fatalf("half refilled bucket returned %v, %v", ok, wait)
+	}
+	if ok, _ := bucket.take(now.Add(2 * time.Second)); !ok {
+		t.Fatal("refilled token rejected")
+	}
+	// The bucket does not fill up beyond its burst
+	later := now.Add(time.Hour)
+	if !bucket.full(later) || bucket.tokens != 2 {
+		t.Fatalf("bucket has %v tokens", bucket.tokens)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{PerIPRate: 0.1, PerIPBurst: 1, GlobalRate: 0.1, GlobalBurst: 2})
+	if ok, _ := limiter.allow("192.0.2.1"); !ok {
+		t.Fatal("first registration rejected")
+	}
+	if ok, wait := limiter.allow("192.0.2.1"); ok || wait <= 0 {
+		t.Fatal("second registration of the same IP accepted")
+	}
+	if ok, _ := limiter.allow("192.0.2.2"); !ok {
+		t.Fatal("registration of another IP rejected")
+	}
+	if ok, _ := limiter.allow("192.0.2.3"); ok {
+		t.Fatal("global limit not enforced")
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	now := time.Now().Unix()
+	c := &Coordinator{
+		config: Config{RateLimit: RateLimitConfig{MaxQueueLength: 3, MaxActivePerIP: 1, PerIPRate: 0.1, PerIPBurst: 2}},
+		slots: []*slot{
+			{index: 0, deadline: now + 30, ip: "192.0.2.1"},
+			{index: 1, deadline: now + 60, ip: "192.0.2.2", submitted: true},
+		},
+	}
+	c.limiter = newRateLimiter(c.config.RateLimit)
+	if ok, wait, _ := c.checkLimits("192.0.2.1"); ok || wait < 29*time.Second || wait > 30*time.Second {
+		t.Fatalf("second active registration returned %v, %v", ok, wait)
+	}
+	// Submitted slots don't count as active
+	for i := 0; i < 2; i++ {
+		if ok, _, reason := c.checkLimits("192.0.2.2"); !ok {
+			t.Fatalf("registration %v rejected: %v", i, reason)
+		}
+	}
+	if ok, wait, reason := c.checkLimits("192.0.2.2"); ok || reason != "rate limit exceeded" || wait <= 0 {
+		t.Fatalf("rate limit returned %v, %v, %v", ok, wait, reason)
+	}
+	c.slots = append(c.slots, &slot{index: 2, deadline: now + 90, ip: "192.0.2.3"})
+	if ok, wait, reason := c.checkLimits("192.0.2.4"); ok || reason != "queue is full" || wait > 30*time.Second {
+		t.Fatalf("full queue returned %v, %v, %v", ok, wait, reason)
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	for _, test := range []struct {
+		wait  time.Duration
+		after string
+	}{
+		{0, "1"},
+		{300 * time.Millisecond, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Minute, "60"},
+	} {
+		rw := httptest.NewRecorder()
+		tooManyRequests(rw, test.wait, "rate limit exceeded")
+		if rw.Code != 429 {
+			t.Fatalf("status code %v", rw.Code)
+		}
+		if after := rw.Header().Get("Retry-After"); after != test.after {
+			t.Fatalf("Retry-After of %v is %v, expected %v", test.wait, after, test.after)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseProxies([]string{"10.0.0.1", "192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		remote    string
+		forwarded []string
+		proxies   bool
+		ip        string
+	}{
+		// Without trusted proxies the header is ignored
+		{"203.0.113.1:1234", []string{"198.51.100.1"}, false, "203.0.113.1"},
+		// Only a trusted proxy can set the header
+		{"203.0.113.1:1234", []string{"198.51.100.1"}, true, "203.0.113.1"},
+		{"10.0.0.1:1234", []string{"198.51.100.1"}, true, "198.51.100.1"},
+		// Entries left of the one the proxy appended are sent by the client
+		{"10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, true, "198.51.100.1"},
+		{"10.0.0.1:1234", []string{"1.2.3.4", "198.51.100.1"}, true, "198.51.100.1"},
+		// Chained trusted proxies are skipped
+		{"10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 192.168.1.1"}, true, "198.51.100.1"},
+		// Garbage and missing entries fall back to the last trusted proxy
+		{"10.0.0.1:1234", []string{"198.51.100.1, garbage, 192.168.1.1"}, true, "192.168.1.1"},
+		{"10.0.0.1:1234", nil, true, "10.0.0.1"},
+	} {
+		req := httptest.NewRequest("POST", "/participation", nil)
+		req.RemoteAddr = test.remote
+		for _, header := range test.forwarded {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		trusted := proxies
+		if !test.proxies {
+			trusted = nil
+		}
+		if ip := clientIP(req, trusted); ip != test.ip {
+			t.Fatalf("%v with %v: got %v, expected %v", test.remote, test.forwarded, ip, test.ip)
+		}
+	}
+	if _, err := parseProxies([]string{"10.0.0"}); err == nil {
+		t.Fatal("invalid proxy accepted")
+	}
+}
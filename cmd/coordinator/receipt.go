@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// receiptKeyFile is the default file the generated receipt key is kept in
+const receiptKeyFile = "receipt.key"
+
+// receiptKey decodes the hex encoded ed25519 seed of the config. Without a configured key the key is read
+// from the receipt key file, it is generated and saved there on the first start, s.th. receipts and the log
+// stay verifiable with the same key after a restart. The file must not be in the published history directory.
+func receiptKey(config Config) (ed25519.PrivateKey, error) {
+	if config.ReceiptKey != "" {
+		return decodeReceiptKey(config.ReceiptKey)
+	}
+	path := config.ReceiptKeyFile
+	if path == "" {
+		return nil, errors.New("neither a receipt key nor a receipt key file is configured")
+	}
+	if published, err := inDirectory(config.HistoryDir, path); err != nil {
+		return nil, err
+	} else if published {
+		return nil, fmt.Errorf("receipt key file %v is in the published history directory", path)
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := decodeReceiptKey(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// O_EXCL, s.th. a key written concurrently is never replaced
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(file, "0x%x\n", key.Seed()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	fmt.Printf("No receipt key configured, generated one and saved it to %v\n", path)
+	return key, nil
+}
+
+// inDirectory returns whether path lies within dir.
+func inDirectory(dir, path string) (bool, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// decodeReceiptKey decodes a hex encoded ed25519 seed.
+func decodeReceiptKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("receipt key has to be a 32 byte ed25519 seed")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// writeReceipt signs the receipt and sends it to the participant.
+func (c *Coordinator) writeReceipt(rw http.ResponseWriter, status int, receipt towersofpau.Receipt) {
+	receipt.Ceremony = c.id
+	if receipt.Timestamp == 0 {
+		receipt.Timestamp = time.Now().Unix()
+	}
+	signed, err := towersofpau.SignReceipt(receipt, c.receiptKey)
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	resp, err := json.Marshal(signed)
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(resp)
+}
+
+func hashBytes(b []byte) string {
+	hash := sha256.Sum256(b)
+	return "0x" + hex.EncodeToString(hash[:])
+}
+
+// ceremonyHash identifies the ceremony by its hash tree root.
+func ceremonyHash(ceremony *towersofpau.Ceremony) (string, error) {
+	root, err := ceremony.HashTreeRoot()
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(root[:]), nil
+}
+
+// submissionHash identifies a rejected submission, by the body if it is no valid ceremony.
+func submissionHash(ceremony *towersofpau.Ceremony, body []byte) string {
+	if ceremony != nil {
+		if hash, err := ceremonyHash(ceremony); err == nil {
+			return hash
+		}
+	}
+	return hashBytes(body)
+}
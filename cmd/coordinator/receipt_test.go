@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestReceiptKey(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.HistoryDir = filepath.Join(dir, "history")
+	config.ReceiptKeyFile = filepath.Join(dir, "keys", "receipt.key")
+	key, err := receiptKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The generated key is kept across restarts
+	again, err := receiptKey(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(again) {
+		t.Fatal("generated receipt key was not reused")
+	}
+
+	// The key must not be published with the history
+	for _, path := range []string{
+		filepath.Join(config.HistoryDir, "receipt.key"),
+		filepath.Join(config.HistoryDir, "ceremony", "receipt.key"),
+	} {
+		config.ReceiptKeyFile = path
+		if _, err := receiptKey(config); err == nil {
+			t.Fatalf("receipt key file %v in the history directory accepted", path)
+		}
+	}
+	config.ReceiptKeyFile = filepath.Join(dir, "history.key")
+	if _, err := receiptKey(config); err != nil {
+		t.Fatal(err)
+	}
+
+	config.ReceiptKey = "0x" + "11111111111111111111111111111111" + "11111111111111111111111111111111"
+	config.ReceiptKeyFile = ""
+	if _, err := receiptKey(config); err != nil {
+		t.Fatal(err)
+	}
+	config.ReceiptKey = ""
+	if _, err := receiptKey(config); err == nil {
+		t.Fatal("missing receipt key accepted")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// maxAlternatives is the number of alternative windows offered if a booking fails.
+const maxAlternatives = 3
+
+// gaps calls fn with every gap of the schedule a new slot could be inserted in, in order of time,
+// until fn returns false. A slot inserted at position has to start at or after start and end,
+// including its verification, before end. The last gap has an end of 0 and is open ended.
+// Mutex has to be held.
+func (c *Coordinator) gaps(fn func(position int, start, end int64) bool) {
+	position, earliest := c.firstOpenPosition()
+	for ; position < len(c.slots); position++ {
+		next := c.slots[position]
+		if !fn(position, earliest, next.start-coordinatorTime) {
+			return
+		}
+		if end := next.deadline + coordinatorTime; end > earliest {
+			earliest = end
+		}
+	}
+	fn(len(c.slots), earliest, 0)
+}
+
+// firstOpenPosition returns the first position a new slot can be inserted at and the earliest time it can start.
+// Nobody can be scheduled before a slot that already started, in pipelined mode several slots
+// can be running at once. Mutex has to be held.
+func (c *Coordinator) firstOpenPosition() (int, int64) {
+	earliest := time.Now().Unix() + immediateStartDelay
+	position := c.currentSlot
+	for ; position < len(c.slots); position++ {
+		current := c.slots[position]
+		if !current.notified && current.fetched.IsZero() && current.start > earliest {
+			break
+		}
+		if end := current.deadline + coordinatorTime; end > earliest {
+			earliest = end
+		}
+	}
+	return position, earliest
+}
+
+// findPrioritySlot returns the position and start of a slot of length seconds behind the waiting
+// priority participants but ahead of the public queue, which is pushed back to make room.
+// Reserved slots keep their time, if the public queue cannot be pushed back in front of one
+// the slot is scheduled like any other. Mutex has to be held.
+func (c *Coordinator) findPrioritySlot(length int64) (int, int64, bool) {
+	position, start := c.firstOpenPosition()
+	for position < len(c.slots) && c.slots[position].priority {
+		if end := c.slots[position].deadline + coordinatorTime; end > start {
+			start = end
+		}
+		position++
+	}
+	if position < len(c.slots) {
+		if delay := start + length + coordinatorTime - c.slots[position].start; delay > 0 && !c.pushBack(position, delay) {
+			return c.findSlot(towersofpau.TimeWindow{}, length)
+		}
+	}
+	return position, start, true
+}
+
+// findSlot returns the position and start of the earliest slot of length seconds
+// that lies within window. A window end of 0 is open ended. Mutex has to be held.
+func (c *Coordinator) findSlot(window towersofpau.TimeWindow, length int64) (int, int64, bool) {
+	var (
+		found    bool
+		position int
+		start    int64
+	)
+	c.gaps(func(gapPosition int, gapStart, gapEnd int64) bool {
+		if gapStart < window.Start {
+			gapStart = window.Start
+		}
+		if window.End != 0 && gapStart+length > window.End {
+			// All later gaps start even later
+			return false
+		}
+		if gapEnd != 0 && gapStart+length > gapEnd {
+			return true
+		}
+		found, position, start = true, gapPosition, gapStart
+		return false
+	})
+	return position, start, found
+}
+
+// alternatives returns the earliest windows a slot of length seconds could be booked in.
+// Mutex has to be held.
+func (c *Coordinator) alternatives(length int64) []towersofpau.TimeWindow {
+	var windows []towersofpau.TimeWindow
+	c.gaps(func(_ int, start, end int64) bool {
+		if end == 0 || start+length <= end {
+			windows = append(windows, towersofpau.TimeWindow{Start: start, End: end})
+		}
+		return len(windows) < maxAlternatives
+	})
+	return windows
+}
+
+// insertSlot adds slot to the schedule at position and renumbers the slots behind it.
+// Mutex has to be held.
+func (c *Coordinator) insertSlot(position int, slot *slot) {
+	c.slots = append(c.slots, nil)
+	copy(c.slots[position+1:], c.slots[position:])
+	c.slots[position] = slot
+	for i := position; i < len(c.slots); i++ {
+		c.slots[i].index = i
+	}
+}
+
+// pushBack delays the slots from position on by delay seconds. Reserved slots keep their
+// time, so only the slots before the next reservation are moved and only if they still fit.
+// Mutex has to be held.
+func (c *Coordinator) pushBack(position int, delay int64) bool {
+	end := position
+	for end < len(c.slots) && !c.slots[end].reserved {
+		end++
+	}
+	if end == position {
+		return false
+	}
+	if end < len(c.slots) && c.slots[end-1].deadline+delay+coordinatorTime > c.slots[end].start {
+		return false
+	}
+	for _, slot := range c.slots[position:end] {
+		slot.start += delay
+		slot.deadline += delay
+	}
+	return true
+}
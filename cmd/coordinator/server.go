@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
@@ -15,6 +19,7 @@ import (
 )
 
 const (
+	// participantTime is the slot length for participants without benchmark
 	participantTime     = 20
 	coordinatorTime     = 60
 	immediateStartDelay = 5
@@ -22,16 +27,74 @@ const (
 	rounds              = 10
 )
 
-func NewCoordinator(initialCeremony *towersofpau.Ceremony) *Coordinator {
-	return &Coordinator{
-		slotByTicket: make(map[string]*slot),
-		slots:        make([]*slot, 0),
-		ceremony:     initialCeremony,
-		maxRounds:    rounds,
+func NewCoordinator(id string, initialCeremony *towersofpau.Ceremony, config Config, receiptKey ed25519.PrivateKey) (*Coordinator, error) {
+	if config.Pipelined && config.Optimistic {
+		return nil, errors.New("optimistic verification is not supported in pipelined mode")
 	}
+	if err := checkQuorum(config.Verification); err != nil {
+		return nil, err
+	}
+	if config.Identity.RequireEthereum && config.Identity.Domain == "" {
+		return nil, errors.New("sign-in with ethereum requires the domain of the coordinator")
+	}
+	if _, err := ceremonyHash(initialCeremony); err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := towersofpau.Serialize(buf, initialCeremony); err != nil {
+		return nil, err
+	}
+	pubkeys, err := loadPubkeys(config.HistoryDir, initialCeremony)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := parseProxies(config.RateLimit.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	challengeKey := make([]byte, 32)
+	if _, err := rand.Read(challengeKey); err != nil {
+		return nil, err
+	}
+	c := &Coordinator{
+		id:              id,
+		historyDir:      config.HistoryDir,
+		slotByTicket:    make(map[string]*slot),
+		slots:           make([]*slot, 0),
+		ceremony:        initialCeremony,
+		maxRounds:       rounds,
+		config:          config,
+		limiter:         newRateLimiter(config.RateLimit),
+		signInLimiter:   newRateLimiter(config.RateLimit),
+		proxies:         proxies,
+		challengeKey:    challengeKey,
+		redeemed:        make(map[string]bool),
+		signIns:         make(map[string]signIn),
+		slotByIdentity:  make(map[string]*slot),
+		contributors:    make(map[string]bool),
+		receiptKey:      receiptKey,
+		history:         make([]towersofpau.Contribution, 0),
+		log:             make([]towersofpau.SignedLogEntry, 0),
+		logIndex:        make(map[string]int),
+		currentCeremony: newCachedBytes(buf.Bytes()),
+		historyPages:    make(map[int]*cachedBytes),
+		historyEntries:  newEntryCache(historyCacheSize),
+		events:          newEventBroker(),
+		durations:       newDurationStats(config.Slots),
+		policy:          newPolicy(config.Policy),
+		pubkeys:         pubkeys,
+	}
+	if err := c.loadHistory(); err != nil {
+		return nil, fmt.Errorf("unable to continue the history in %v: %v", config.HistoryDir, err)
+	}
+	c.signTreeHead()
+	return c, nil
 }
 
 type Coordinator struct {
+	// id of the ceremony, every ceremony has its own coordinator
+	id            string
+	historyDir    string
 	mutex         sync.Mutex
 	slotByTicket  map[string]*slot
 	slots         []*slot
@@ -39,37 +102,134 @@ type Coordinator struct {
 	ceremony      *towersofpau.Ceremony
 	ceremonyMutex sync.Mutex
 	maxRounds     int
+	config        Config
+	limiter       *rateLimiter
+	// signInLimiter limits the sign-in messages handed out, with the same limits as registrations
+	signInLimiter *rateLimiter
+	// proxies are the trusted reverse proxies the client IP is taken from X-Forwarded-For for
+	proxies []*net.IPNet
+	// challengeKey authenticates the stateless challenges, redeemed are the used nonces until they expire
+	challengeKey  []byte
+	redeemed      map[string]bool
+	redeemedQueue challengeQueue
+	// signIns are the outstanding sign-in with ethereum messages by nonce
+	signIns        map[string]signIn
+	slotByIdentity map[string]*slot
+	// contributors are the identities that contributed before the coordinator was restarted
+	contributors map[string]bool
+	// receiptKey is the long-term key receipts are signed with
+	receiptKey ed25519.PrivateKey
+
+	historyMutex    sync.RWMutex
+	history         []towersofpau.Contribution
+	currentCeremony *cachedBytes
+	historyPages    map[int]*cachedBytes
+	// historyEntries caches the ceremonies of the history, it has its own mutex
+	historyEntries *entryCache
+	// log is the hash-chained log of the contributions, one entry per contribution in history
+	log []towersofpau.SignedLogEntry
+	// leaves are the leaf hashes of the log entries, logIndex maps ceremony hashes to their entries
+	leaves   [][32]byte
+	logIndex map[string]int
+	treeHead *towersofpau.SignedTreeHead
+	// status is cached for statusCacheTime seconds, guarded by mutex
+	status     *cachedBytes
+	statusTime int64
+
+	events *eventBroker
+	// durations of previous slots, guarded by mutex
+	durations durationStats
+	// policy decides who may register, guarded by mutex
+	policy *policy
+	// pending are the contributions handed on before their pairing check finished, oldest first, guarded by mutex
+	pending []*pendingContribution
+	// pubkeys are the pot pubkeys of all accepted contributions, including those of earlier runs
+	pubkeys *towersofpau.PubkeyIndex
+	// beacon the ceremony was finalized with, nobody can register once it is set. Guarded by mutex.
+	beacon *towersofpau.Beacon
 }
 
 func (c *Coordinator) RegisterParticipant(rw http.ResponseWriter, req *http.Request) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
+	ip := clientIP(req, c.proxies)
+	var request towersofpau.RegistrationRequest
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(rw, "invalid registration request", 400)
+		return
+	}
+	challenge, err := c.checkChallenge(&request)
+	if err != nil {
+		http.Error(rw, err.Error(), 403)
+		return
+	}
 	slot := new(slot)
-	slot.index = len(c.slots)
-	// check if current slot has expired or is in processing
-	for c.currentSlot < len(c.slots) {
-		if c.slots[c.currentSlot].submitted || c.slots[c.currentSlot].deadline >= time.Now().Unix() {
-			break
-		} else {
-			c.currentSlot++
+	c.advanceSlots()
+	identity, err := c.checkIdentity(&request)
+	if err == errAlreadyRegistered {
+		http.Error(rw, err.Error(), 409)
+		return
+	} else if err != nil {
+		http.Error(rw, err.Error(), 403)
+		return
+	}
+	priority, err := c.checkPolicy(ip, identity, request.Invite)
+	if err != nil {
+		fmt.Printf("Rejected registration from %v: %v\n", ip, err)
+		http.Error(rw, err.Error(), 403)
+		return
+	}
+	if ok, retryAfter, reason := c.checkLimits(ip); !ok {
+		fmt.Printf("Rejected registration from %v: %v\n", ip, reason)
+		tooManyRequests(rw, retryAfter, reason)
+		return
+	}
+	slot.ip = ip
+	slot.identity = identity
+	length := c.participantTime(request.Benchmark)
+	window := towersofpau.TimeWindow{Start: request.WindowStart, End: request.WindowEnd}
+	slot.reserved = window.Start != 0 || window.End != 0
+	if slot.reserved {
+		if err := c.checkWindow(window, length); err != nil {
+			http.Error(rw, err.Error(), 400)
+			return
 		}
 	}
-	if c.currentSlot == slot.index {
-		slot.start = time.Now().Unix() + immediateStartDelay
+	var (
+		position int
+		start    int64
+		ok       bool
+	)
+	if priority && !slot.reserved {
+		slot.priority = true
+		position, start, ok = c.findPrioritySlot(length)
 	} else {
-		slot.start = c.slots[len(c.slots)-1].deadline + coordinatorTime
-		if time.Now().Unix()+immediateStartDelay > slot.start {
-			slot.start = time.Now().Unix() + immediateStartDelay
-		}
+		position, start, ok = c.findSlot(window, length)
 	}
-	slot.deadline = slot.start + participantTime
+	if !ok {
+		bookingConflict(rw, "requested window is already booked", c.alternatives(length))
+		return
+	}
+	// Only redeem the challenge once the registration succeeds, s.th. it can be retried after a rejection
+	c.redeemChallenge(challenge)
+	slot.start = start
+	slot.deadline = slot.start + length
 	slot.participantTicket = getTicket()
-	c.slots = append(c.slots, slot)
+	c.insertSlot(position, slot)
 	c.slotByTicket[slot.participantTicket] = slot
+	if identity != "" {
+		c.slotByIdentity[identity] = slot
+	}
+	if slot.index < len(c.slots)-1 {
+		// Everyone behind the new slot moved back in the queue
+		c.notifyQueue()
+	}
+	c.events.publish("", "queue", c.queueStatus())
 	resp, err := json.Marshal(towersofpau.RegistrationResponse{
 		Start:    slot.start,
 		Deadline: slot.deadline,
 		Ticket:   slot.participantTicket,
+		Identity: slot.identity,
 	})
 	if err != nil {
 		rw.WriteHeader(500)
@@ -79,6 +239,84 @@ func (c *Coordinator) RegisterParticipant(rw http.ResponseWriter, req *http.Requ
 	fmt.Printf("Registered participant no. %v for %v\n", slot.index, time.Unix(slot.start, 0))
 }
 
+// advanceSlots skips the current slot if it has expired without submission, mutex has to be held.
+func (c *Coordinator) advanceSlots() {
+	advanced := false
+	// check if current slot has expired or is in processing
+	for c.currentSlot < len(c.slots) {
+		current := c.slots[c.currentSlot]
+		if c.heartbeatMissed(current) {
+			fmt.Printf("Participant no. %v stopped sending heartbeats, releasing slot\n", current.index)
+			current.released = true
+			c.strike(current, "stopped sending heartbeats")
+		}
+		if current.submitted || (!current.released && !current.contributed && current.deadline >= time.Now().Unix()) {
+			break
+		} else {
+			if !current.released && !current.contributed {
+				c.strike(current, "missed its slot")
+			}
+			c.currentSlot++
+			advanced = true
+		}
+	}
+	if c.config.Pipelined {
+		advanced = c.releaseStalledSlots() || advanced
+	}
+	if advanced {
+		c.pullForward()
+		c.notifyQueue()
+	}
+}
+
+// finishSlot moves on to the next slot after the submission of the current slot has been processed.
+func (c *Coordinator) finishSlot() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.currentSlot++
+	c.pullForward()
+	c.notifyQueue()
+	c.notifyTurn()
+}
+
+// slotFinished returns whether the slot is over, either by submission or by missing the deadline.
+// Pipelined slots are over once all transcripts have been accepted.
+func (c *Coordinator) slotFinished(slot *slot) bool {
+	return slot.index < c.currentSlot || slot.released || (slot.contributed && !slot.submitted)
+}
+
+// checkLimits checks the queue and rate limits for a new registration from ip.
+func (c *Coordinator) checkLimits(ip string) (bool, time.Duration, string) {
+	now := time.Now().Unix()
+	limits := c.config.RateLimit
+	pending := c.slots[c.currentSlot:]
+	if limits.MaxQueueLength > 0 && len(pending) >= limits.MaxQueueLength {
+		// A place in the queue frees up once the current participant is done
+		return false, time.Duration(pending[0].deadline-now) * time.Second, "queue is full"
+	}
+	if limits.MaxActivePerIP > 0 {
+		var (
+			active        int
+			firstDeadline int64
+		)
+		for _, s := range pending {
+			if s.ip == ip && !s.submitted && !s.released {
+				if active == 0 {
+					firstDeadline = s.deadline
+				}
+				active++
+			}
+		}
+		if active >= limits.MaxActivePerIP {
+			return false, time.Duration(firstDeadline-now) * time.Second, "too many active registrations"
+		}
+	}
+	if ok, wait := c.limiter.allow(ip); !ok {
+		return false, wait, "rate limit exceeded"
+	}
+	return true, 0, ""
+}
+
 func getTicket() string {
 	b := make([]byte, 32)
 	l, err := rand.Read(b)
@@ -104,18 +342,20 @@ func (c *Coordinator) RetrieveParticipant(rw http.ResponseWriter, req *http.Requ
 		Ceremony: nil,
 	}
 
-	// check if current slot has expired or is in processing
-	for c.currentSlot < slot.index {
-		if c.slots[c.currentSlot].submitted || c.slots[c.currentSlot].deadline >= time.Now().Unix() {
-			break
-		} else {
-			c.currentSlot++
-		}
-	}
+	c.advanceSlots()
 
-	if c.currentSlot == slot.index {
+	if c.config.Pipelined {
+		c.retrieveTranscript(rw, slot, response)
+		return
+	}
+	if c.currentSlot > slot.index {
+		rw.WriteHeader(403)
+		return
+	} else if c.currentSlot == slot.index {
 		if slot.deadline < time.Now().Unix() {
+			c.strike(slot, "missed its slot")
 			c.currentSlot++
+			c.notifyQueue()
 			rw.WriteHeader(403)
 			return
 		} else {
@@ -126,15 +366,19 @@ func (c *Coordinator) RetrieveParticipant(rw http.ResponseWriter, req *http.Requ
 			}
 			fmt.Printf("Participant no. %v retrieved ceremony\n", slot.index)
 			response.Ceremony = &jsonceremony
+			slot.basedOn = c.lastPending()
+			if slot.fetched.IsZero() {
+				slot.fetched = time.Now()
+				slot.lastHeartbeat = slot.fetched
+			}
 		}
 	} else if c.currentSlot < slot.index && slot.start <= time.Now().Unix()+1 {
-		for _, slot := range c.slots[c.currentSlot+1:] {
-			slot.start += pushbackDelay
-			slot.deadline += pushbackDelay
-		}
+		c.pushBack(c.currentSlot+1, pushbackDelay)
 		response.Start = slot.start
 		response.Deadline = slot.deadline
+		c.notifyQueue()
 	}
+	response.QueueInfo = c.queueInfo(slot)
 
 	resp, err := json.Marshal(response)
 	if err != nil {
@@ -153,41 +397,106 @@ func (c *Coordinator) SubmitCeremony(rw http.ResponseWriter, req *http.Request)
 		rw.WriteHeader(403)
 		return
 	}
+	if c.config.Pipelined {
+		c.mutex.Unlock()
+		http.Error(rw, "transcripts have to be submitted individually", 400)
+		return
+	}
 	fmt.Printf("Received submission from %v\n", slot.index)
 	slot.submitted = true
+	slot.submittedAt = time.Now()
+	if !slot.fetched.IsZero() {
+		c.recordContributionTime(slot.submittedAt.Sub(slot.fetched))
+	}
 	c.mutex.Unlock()
 
-	newCeremony, err := towersofpau.Deserialize(req.Body)
+	uploadStart := time.Now()
+	body, err := io.ReadAll(req.Body)
 	if err != nil {
-		c.currentSlot++
+		c.finishSlot()
 		rw.WriteHeader(400)
 		return
 	}
+	c.mutex.Lock()
+	c.recordThroughput(len(body), time.Since(uploadStart))
+	c.mutex.Unlock()
+	newCeremony, err := towersofpau.Deserialize(bytes.NewReader(body))
+	if err != nil {
+		c.rejectSubmission(slot)
+		c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Reason: "invalid ceremony"})
+		c.writeReceipt(rw, 400, towersofpau.Receipt{
+			SlotIndex:    slot.index,
+			Identity:     slot.receiptIdentity(),
+			CeremonyHash: submissionHash(nil, body),
+			Reason:       fmt.Sprintf("invalid ceremony: %v", err),
+		})
+		return
+	}
+
+	if c.config.Optimistic {
+		c.submitOptimistic(rw, slot, body, newCeremony)
+		return
+	}
 
 	c.ceremonyMutex.Lock()
 	defer c.ceremonyMutex.Unlock()
 	oldCeremony := c.ceremony
 	fmt.Printf("Verifying submission from %v\n", slot.index)
 	start := time.Now()
-	if err := towersofpau.VerifySubmission(oldCeremony, newCeremony); err != nil {
-		c.currentSlot++
+	var identity string
+	if c.config.Identity.RequireBLSSignatures {
+		identity = slot.identity
+	}
+	err = c.verify(oldCeremony, newCeremony, identity, false)
+	if err == nil {
+		err = c.usePubkeys(newCeremony.LatestPotPubkeys())
+	}
+	c.mutex.Lock()
+	c.recordVerificationTime(time.Since(start))
+	c.mutex.Unlock()
+	if err != nil {
+		c.rejectSubmission(slot)
 		fmt.Printf("Submission verification from %v failed: %v\n", slot.index, err)
-		rw.WriteHeader(400)
+		c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Reason: err.Error()})
+		c.writeReceipt(rw, 400, towersofpau.Receipt{
+			SlotIndex:    slot.index,
+			Identity:     slot.receiptIdentity(),
+			PotPubkeys:   newCeremony.LatestPotPubkeys(),
+			CeremonyHash: submissionHash(newCeremony, body),
+			Reason:       err.Error(),
+		})
 		return
 	}
 	fmt.Printf("Submission verified successfully in %v\n", time.Since(start))
 	// Ceremony was valid, store it
 	c.ceremony = newCeremony
-	rw.WriteHeader(200)
-
-	c.currentSlot++
+	slot.contributed = true
+	c.events.publish("", "verification", towersofpau.VerificationEvent{Slot: slot.index, Accepted: true})
 
-	file, err := os.Create(fmt.Sprintf("history/%d.json", slot.index))
+	buf := new(bytes.Buffer)
+	if err := towersofpau.Serialize(buf, newCeremony); err != nil {
+		c.finishSlot()
+		rw.WriteHeader(500)
+		return
+	}
+	hash, err := ceremonyHash(newCeremony)
 	if err != nil {
+		c.finishSlot()
+		rw.WriteHeader(500)
 		return
 	}
-	towersofpau.Serialize(file, newCeremony)
-	return
+	timestamp := time.Now().Unix()
+	c.writeReceipt(rw, 200, towersofpau.Receipt{
+		SlotIndex:    slot.index,
+		Identity:     slot.receiptIdentity(),
+		PotPubkeys:   newCeremony.LatestPotPubkeys(),
+		CeremonyHash: hash,
+		Timestamp:    timestamp,
+		Accepted:     true,
+	})
+
+	c.recordContribution(slot, newCeremony, buf.Bytes(), hash, timestamp, nil)
+	c.finishSlot()
 }
 
 type slot struct {
@@ -196,4 +505,31 @@ type slot struct {
 	deadline          int64
 	participantTicket string
 	submitted         bool
+	contributed       bool
+	// notified is set once the participant has been told that its turn started
+	notified    bool
+	fetched     time.Time
+	submittedAt time.Time
+	// lastHeartbeat is the last sign of life of the participant after fetching the ceremony
+	lastHeartbeat time.Time
+	// released is set if the participant gave up its slot or stopped sending heartbeats
+	released bool
+	// reserved slots were booked for a window and keep their time when the schedule moves
+	reserved bool
+	// priority slots are scheduled ahead of the public queue
+	priority bool
+	// progress is the number of transcripts accepted from the participant in pipelined mode
+	progress int
+	// basedOn is the unverified contribution the fetched ceremony is the result of in optimistic mode
+	basedOn  *pendingContribution
+	ip       string
+	identity string
+}
+
+// receiptIdentity identifies the participant on receipts, anonymous participants are identified by their ticket
+func (s *slot) receiptIdentity() string {
+	if s.identity != "" {
+		return s.identity
+	}
+	return s.participantTicket
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxOutstandingSignIns bounds the memory used by issued but unused sign-in messages
+const maxOutstandingSignIns = 100000
+
+var errAlreadyRegistered = errors.New("address already registered or contributed")
+
+type signIn struct {
+	address common.Address
+	message string
+	expires int64
+}
+
+// IssueSignIn hands out a Sign-In with Ethereum message for the address in the query.
+func (c *Coordinator) IssueSignIn(rw http.ResponseWriter, req *http.Request) {
+	if c.config.Identity.Domain == "" {
+		http.Error(rw, "sign-in with ethereum is not configured", 404)
+		return
+	}
+	address := req.URL.Query().Get("address")
+	if !common.IsHexAddress(address) {
+		http.Error(rw, "invalid address", 400)
+		return
+	}
+	if ok, wait := c.signInLimiter.allow(clientIP(req, c.proxies)); !ok {
+		tooManyRequests(rw, wait, "rate limit exceeded")
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	for nonce, s := range c.signIns {
+		if s.expires < now.Unix() {
+			delete(c.signIns, nonce)
+		}
+	}
+	if len(c.signIns) >= maxOutstandingSignIns {
+		tooManyRequests(rw, time.Duration(c.config.Challenge.Lifetime)*time.Second, "too many outstanding sign-ins")
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("invalid randomness")
+	}
+	nonce := common.Bytes2Hex(b)
+	expires := now.Add(time.Duration(c.config.Challenge.Lifetime) * time.Second)
+	s := signIn{
+		address: common.HexToAddress(address),
+		message: towersofpau.SIWEMessage(c.config.Identity.Domain, common.HexToAddress(address), nonce, now, expires),
+		expires: expires.Unix(),
+	}
+	c.signIns[nonce] = s
+	resp, err := json.Marshal(towersofpau.SignInChallenge{
+		Nonce:   nonce,
+		Message: s.message,
+		Expires: s.expires,
+	})
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Write(resp)
+}
+
+// checkIdentity verifies the optional sign-in of the request and returns the identity
+// the ticket is bound to. Every identity can only hold one registration at a time
+// and contribute once.
+func (c *Coordinator) checkIdentity(request *towersofpau.RegistrationRequest) (string, error) {
+	if request.SignInNonce == "" {
+		if c.config.Identity.RequireEthereum {
+			return "", errors.New("sign-in with ethereum required")
+		}
+		return "", nil
+	}
+	s, ok := c.signIns[request.SignInNonce]
+	if !ok {
+		return "", errors.New("unknown sign-in")
+	}
+	delete(c.signIns, request.SignInNonce)
+	if s.expires < time.Now().Unix() {
+		return "", errors.New("sign-in expired")
+	}
+	if !common.IsHexAddress(request.Address) || common.HexToAddress(request.Address) != s.address {
+		return "", errors.New("sign-in issued for different address")
+	}
+	if err := towersofpau.VerifySIWE(s.message, common.FromHex(request.Signature), s.address); err != nil {
+		return "", err
+	}
+	identity := towersofpau.EthereumIdentity(s.address)
+	if c.contributors[identity] {
+		return "", errAlreadyRegistered
+	}
+	if previous, ok := c.slotByIdentity[identity]; ok && (previous.contributed || !c.slotFinished(previous)) {
+		return "", errAlreadyRegistered
+	}
+	return identity, nil
+}
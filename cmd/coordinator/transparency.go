@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/gorilla/mux"
+)
+
+const (
+	historyPageSize = 100
+	// historyCacheSize is the number of history entries kept in memory, each of them is a full ceremony
+	historyCacheSize = 4
+	// statusCacheTime is the number of seconds the status is served from the cache
+	statusCacheTime = 1
+	phaseOpen       = "open"
+	phaseClosed     = "closed"
+	phaseFinalized  = "finalized"
+)
+
+// cachedBytes is a response body that is only serialized once.
+type cachedBytes struct {
+	body []byte
+	etag string
+}
+
+func newCachedBytes(body []byte) *cachedBytes {
+	hash := sha256.Sum256(body)
+	return &cachedBytes{
+		body: body,
+		etag: `"` + hex.EncodeToString(hash[:]) + `"`,
+	}
+}
+
+func newCachedJSON(v interface{}) (*cachedBytes, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return newCachedBytes(body), nil
+}
+
+func (b *cachedBytes) serve(rw http.ResponseWriter, req *http.Request) {
+	serveWithETag(rw, req, b.etag, func() ([]byte, error) { return b.body, nil })
+}
+
+// serveWithETag answers conditional requests with a 304, the body is only loaded if needed.
+func serveWithETag(rw http.ResponseWriter, req *http.Request, etag string, body func() ([]byte, error)) {
+	rw.Header().Set("ETag", etag)
+	rw.Header().Set("Content-Type", "application/json")
+	for _, match := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		if strings.TrimSpace(match) == etag || strings.TrimSpace(match) == "*" {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	b, err := body()
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	http.ServeContent(rw, req, "", time.Time{}, bytes.NewReader(b))
+}
+
+// entryCache keeps the most recently served history entries, the files never change once written.
+type entryCache struct {
+	mutex   sync.Mutex
+	size    int
+	entries map[int]*list.Element
+	// order holds the cached entries, most recently used first
+	order *list.List
+}
+
+type cachedEntry struct {
+	index int
+	body  []byte
+}
+
+func newEntryCache(size int) *entryCache {
+	return &entryCache{
+		size:    size,
+		entries: make(map[int]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the entry with the index, it is loaded with load if it is not cached. Entries are loaded
+// one at a time, s.th. clients can not make the coordinator read many ceremonies at once.
+func (e *entryCache) get(index int, load func() ([]byte, error)) ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if element, ok := e.entries[index]; ok {
+		e.order.MoveToFront(element)
+		return element.Value.(*cachedEntry).body, nil
+	}
+	body, err := load()
+	if err != nil {
+		return nil, err
+	}
+	e.entries[index] = e.order.PushFront(&cachedEntry{index: index, body: body})
+	if e.order.Len() > e.size {
+		oldest := e.order.Remove(e.order.Back()).(*cachedEntry)
+		delete(e.entries, oldest.index)
+	}
+	return body, nil
+}
+
+// CurrentCeremony serves the latest accepted ceremony.
+func (c *Coordinator) CurrentCeremony(rw http.ResponseWriter, req *http.Request) {
+	c.historyMutex.RLock()
+	current := c.currentCeremony
+	c.historyMutex.RUnlock()
+	current.serve(rw, req)
+}
+
+// History serves a page of the accepted contributions.
+func (c *Coordinator) History(rw http.ResponseWriter, req *http.Request) {
+	page := 0
+	if p := req.URL.Query().Get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 0 {
+			http.Error(rw, "invalid page", 400)
+			return
+		}
+	}
+	c.historyMutex.Lock()
+	cached, ok := c.historyPages[page]
+	if !ok {
+		from, to := page*historyPageSize, (page+1)*historyPageSize
+		if from > len(c.history) {
+			from = len(c.history)
+		}
+		if to > len(c.history) {
+			to = len(c.history)
+		}
+		var err error
+		cached, err = newCachedJSON(towersofpau.HistoryPage{
+			Page:          page,
+			PageSize:      historyPageSize,
+			Total:         len(c.history),
+			Contributions: c.history[from:to],
+		})
+		if err != nil {
+			c.historyMutex.Unlock()
+			rw.WriteHeader(500)
+			return
+		}
+		// Pages past the end change with every contribution, don't cache them
+		if from < to {
+			c.historyPages[page] = cached
+		}
+	}
+	c.historyMutex.Unlock()
+	cached.serve(rw, req)
+}
+
+// HistoryEntry serves the ceremony after the contribution with the index.
+func (c *Coordinator) HistoryEntry(rw http.ResponseWriter, req *http.Request) {
+	index, err := strconv.Atoi(mux.Vars(req)["index"])
+	c.historyMutex.RLock()
+	if err != nil || index < 0 || index >= len(c.history) {
+		c.historyMutex.RUnlock()
+		rw.WriteHeader(404)
+		return
+	}
+	etag := `"` + strings.TrimPrefix(c.history[index].CeremonyHash, "0x") + `"`
+	c.historyMutex.RUnlock()
+	serveWithETag(rw, req, etag, func() ([]byte, error) {
+		return c.historyEntries.get(index, func() ([]byte, error) {
+			return os.ReadFile(c.historyFile(index))
+		})
+	})
+}
+
+// Log serves the signed log entries, starting at the index given by the from parameter.
+func (c *Coordinator) Log(rw http.ResponseWriter, req *http.Request) {
+	from := 0
+	if f := req.URL.Query().Get("from"); f != "" {
+		var err error
+		from, err = strconv.Atoi(f)
+		if err != nil || from < 0 {
+			http.Error(rw, "invalid from", 400)
+			return
+		}
+	}
+	c.historyMutex.RLock()
+	if from > len(c.log) {
+		from = len(c.log)
+	}
+	resp, err := json.Marshal(c.log[from:])
+	c.historyMutex.RUnlock()
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(resp)
+}
+
+// Status serves an overview of the ceremony.
+func (c *Coordinator) Status(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	if c.status == nil || c.statusTime+statusCacheTime <= time.Now().Unix() {
+		status := c.queueStatus()
+		cached, err := newCachedJSON(status)
+		if err != nil {
+			c.mutex.Unlock()
+			rw.WriteHeader(500)
+			return
+		}
+		c.status = cached
+		c.statusTime = status.ServerTime
+	}
+	status := c.status
+	c.mutex.Unlock()
+	status.serve(rw, req)
+}
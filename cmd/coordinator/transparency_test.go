@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestEntryCache(t *testing.T) {
+	cache := newEntryCache(2)
+	loads := 0
+	get := func(index int) string {
+		body, err := cache.get(index, func() ([]byte, error) {
+			loads++
+			return []byte(strconv.Itoa(index)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	for _, index := range []int{0, 1, 0, 1} {
+		if body := get(index); body != strconv.Itoa(index) {
+			t.Fatalf("entry %v is %v", index, body)
+		}
+	}
+	if loads != 2 {
+		t.Fatalf("%v loads for two entries", loads)
+	}
+	// 0 is evicted as the least recently used entry
+	get(2)
+	get(1)
+	if loads != 3 {
+		t.Fatalf("%v loads", loads)
+	}
+	get(0)
+	if loads != 4 || len(cache.entries) != 2 {
+		t.Fatalf("%v loads, %v entries", loads, len(cache.entries))
+	}
+
+	// Failed loads are not cached
+	if _, err := cache.get(3, func() ([]byte, error) { return nil, errors.New("missing") }); err == nil {
+		t.Fatal("error not returned")
+	}
+	if body := get(3); body != "3" {
+		t.Fatalf("entry 3 is %v", body)
+	}
+}
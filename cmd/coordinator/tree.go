@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// The log entries are the leaves of a Merkle tree following RFC 6962. The coordinator signs a tree
+// head after every contribution, s.th. participants and auditors can check with short proofs that
+// their contribution is included and that everyone is shown the same history.
+
+// appendLeaf adds the entry to the tree and signs the new tree head, historyMutex has to be held.
+func (c *Coordinator) appendLeaf(entry *towersofpau.LogEntry) {
+	leaf, err := entry.LeafHash()
+	if err != nil {
+		fmt.Printf("Unable to hash log entry: %v\n", err)
+		return
+	}
+	c.leaves = append(c.leaves, leaf)
+	c.logIndex[entry.CeremonyHash] = entry.Index
+	c.signTreeHead()
+}
+
+// signTreeHead signs the head of the current tree, historyMutex has to be held.
+func (c *Coordinator) signTreeHead() {
+	root := towersofpau.MerkleRoot(c.leaves)
+	head, err := towersofpau.SignTreeHead(towersofpau.TreeHead{
+		Ceremony:  c.id,
+		Size:      len(c.leaves),
+		RootHash:  towersofpau.EncodeHashes([][32]byte{root})[0],
+		Timestamp: time.Now().Unix(),
+	}, c.receiptKey)
+	if err != nil {
+		fmt.Printf("Unable to sign tree head: %v\n", err)
+		return
+	}
+	c.treeHead = head
+}
+
+// TreeHead serves the latest signed tree head.
+func (c *Coordinator) TreeHead(rw http.ResponseWriter, req *http.Request) {
+	c.historyMutex.RLock()
+	head := c.treeHead
+	c.historyMutex.RUnlock()
+	writeJSON(rw, head)
+}
+
+// InclusionProof serves the proof that the entry given by its index or ceremony hash is part of
+// the tree of the given size, the latest tree if no size is given.
+func (c *Coordinator) InclusionProof(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	c.historyMutex.RLock()
+	defer c.historyMutex.RUnlock()
+	size, ok := queryInt(query.Get("size"), len(c.leaves))
+	if !ok || size > len(c.leaves) {
+		http.Error(rw, "invalid size", 400)
+		return
+	}
+	index, ok := c.logIndex[query.Get("hash")]
+	if !ok {
+		if index, ok = queryInt(query.Get("index"), -1); !ok || index < 0 {
+			rw.WriteHeader(404)
+			return
+		}
+	}
+	if index >= size {
+		rw.WriteHeader(404)
+		return
+	}
+	writeJSON(rw, towersofpau.LogInclusionProof{
+		TreeSize: size,
+		Index:    index,
+		Entry:    c.log[index].Entry,
+		Hashes:   towersofpau.EncodeHashes(towersofpau.InclusionProof(c.leaves[:size], index)),
+	})
+}
+
+// ConsistencyProof serves the proof that the tree of size first is a prefix of the tree of size second,
+// the latest tree if second is not given.
+func (c *Coordinator) ConsistencyProof(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	c.historyMutex.RLock()
+	defer c.historyMutex.RUnlock()
+	second, ok := queryInt(query.Get("second"), len(c.leaves))
+	if !ok || second > len(c.leaves) {
+		http.Error(rw, "invalid second", 400)
+		return
+	}
+	first, ok := queryInt(query.Get("first"), -1)
+	if !ok || first < 0 || first > second {
+		http.Error(rw, "invalid first", 400)
+		return
+	}
+	writeJSON(rw, towersofpau.LogConsistencyProof{
+		First:  first,
+		Second: second,
+		Hashes: towersofpau.EncodeHashes(towersofpau.ConsistencyProof(c.leaves[:second], first)),
+	})
+}
+
+// queryInt parses a non-negative query parameter, def is returned if the parameter is missing.
+func queryInt(value string, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	i, err := strconv.Atoi(value)
+	return i, err == nil && i >= 0
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(resp)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+//go:embed templates/index.html
+var templates embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(templates, "templates/index.html"))
+
+const uiTimeFormat = "2006-01-02 15:04:05 MST"
+
+type uiSlot struct {
+	Index        int
+	Identity     string
+	Start        string
+	Deadline     string
+	DeadlineUnix int64
+	Remaining    string
+	Active       bool
+}
+
+type uiContribution struct {
+	Index        int
+	Time         string
+	Identity     string
+	PotPubkeys   []string
+	CeremonyHash string
+}
+
+type uiPage struct {
+	Ceremony      string
+	Status        towersofpau.Status
+	Active        *uiSlot
+	Queue         []uiSlot
+	Contributions []uiContribution
+}
+
+// Index renders the web interface of the ceremony.
+func (c *Coordinator) Index(rw http.ResponseWriter, req *http.Request) {
+	now := time.Now()
+	page := uiPage{Ceremony: c.id}
+
+	c.mutex.Lock()
+	for _, s := range c.slots[c.currentSlot:] {
+		active := s.index == c.currentSlot && s.start <= now.Unix()
+		entry := uiSlot{
+			Index:        s.index,
+			Identity:     displayIdentity(s.identity),
+			Start:        time.Unix(s.start, 0).UTC().Format(uiTimeFormat),
+			Deadline:     time.Unix(s.deadline, 0).UTC().Format(uiTimeFormat),
+			DeadlineUnix: s.deadline,
+			Remaining:    time.Until(time.Unix(s.deadline, 0)).Round(time.Second).String(),
+			Active:       active,
+		}
+		if active {
+			page.Active = &entry
+		}
+		page.Queue = append(page.Queue, entry)
+	}
+	page.Status = c.queueStatus()
+	c.mutex.Unlock()
+
+	c.historyMutex.RLock()
+	// Newest contributions first
+	for i := len(c.history) - 1; i >= 0; i-- {
+		contribution := c.history[i]
+		page.Contributions = append(page.Contributions, uiContribution{
+			Index:        contribution.Index,
+			Time:         time.Unix(contribution.Timestamp, 0).UTC().Format(uiTimeFormat),
+			Identity:     displayIdentity(contribution.Identity),
+			PotPubkeys:   contribution.PotPubkeys,
+			CeremonyHash: contribution.CeremonyHash,
+		})
+	}
+	c.historyMutex.RUnlock()
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(rw, page); err != nil {
+		rw.WriteHeader(500)
+	}
+}
+
+func displayIdentity(identity string) string {
+	if identity == "" {
+		return "anonymous"
+	}
+	return identity
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// checkQuorum rejects verification configs whose quorum can never be reached.
+func checkQuorum(config VerificationConfig) error {
+	votes := len(config.Verifiers)
+	if config.Local {
+		votes++
+	}
+	if votes == 0 {
+		return errors.New("no verifiers configured")
+	}
+	if config.Quorum < 0 || config.Quorum > votes {
+		return fmt.Errorf("quorum %v can not be reached with %v votes", config.Quorum, votes)
+	}
+	return nil
+}
+
+// verify checks next on top of prev locally and with the remote verifiers in parallel. It returns nil
+// as soon as a quorum approved the submission and an error once the quorum can not be reached anymore.
+// If checked is set, the cheap checks already passed and only the pairing check is run locally.
+func (c *Coordinator) verify(prev, next *towersofpau.Ceremony, identity string, checked bool) error {
+	config := c.config.Verification
+	if len(config.Verifiers) == 0 {
+		return verifyLocally(prev, next, identity, checked)
+	}
+	request, err := verificationRequest(prev, next, identity)
+	if err != nil {
+		return err
+	}
+	votes := len(config.Verifiers)
+	results := make(chan error, votes+1)
+	if config.Local {
+		votes++
+		go func() {
+			if err := verifyLocally(prev, next, identity, checked); err != nil {
+				results <- fmt.Errorf("coordinator: %v", err)
+				return
+			}
+			results <- nil
+		}()
+	}
+	for i, url := range config.Verifiers {
+		go func(i int, url string) {
+			if err := c.verifyRemotely(url, request); err != nil {
+				// The URLs might contain secrets, don't leak them to the participants
+				results <- fmt.Errorf("verifier %v: %v", i, err)
+				return
+			}
+			results <- nil
+		}(i, url)
+	}
+	quorum := config.Quorum
+	if quorum == 0 {
+		quorum = votes
+	}
+	var approved int
+	var reasons []string
+	for i := 0; i < votes; i++ {
+		if err := <-results; err != nil {
+			reasons = append(reasons, err.Error())
+		} else {
+			approved++
+		}
+		if approved >= quorum {
+			return nil
+		}
+		if votes-len(reasons) < quorum {
+			break
+		}
+	}
+	return fmt.Errorf("no quorum: %v", strings.Join(reasons, "; "))
+}
+
+// checkLocally runs the cheap checks of the submission next on top of prev.
+func checkLocally(prev, next *towersofpau.Ceremony, identity string) (err error) {
+	defer recoverCheck(&err)
+	return towersofpau.CheckSubmission(prev, next, identity)
+}
+
+func verifyLocally(prev, next *towersofpau.Ceremony, identity string, checked bool) (err error) {
+	defer recoverCheck(&err)
+	if !checked {
+		return towersofpau.VerifySubmission(prev, next, identity)
+	}
+	if !towersofpau.VerifyPairing(next) {
+		return errors.New("pairing check failed")
+	}
+	return nil
+}
+
+// recoverCheck turns a panic of a check on a malformed submission into an error, s.th. the submission is
+// rejected instead of leaving the state of the coordinator locked. It has to be deferred.
+func recoverCheck(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("invalid ceremony: %v", r)
+	}
+}
+
+func verificationRequest(prev, next *towersofpau.Ceremony, identity string) ([]byte, error) {
+	previous, err := towersofpau.SerializeJSONCeremony(prev)
+	if err != nil {
+		return nil, err
+	}
+	submission, err := towersofpau.SerializeJSONCeremony(next)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(towersofpau.VerificationRequest{
+		Previous:   previous,
+		Submission: submission,
+		Identity:   identity,
+	})
+}
+
+// verifyRemotely posts the verification request to the verifier at url.
+func (c *Coordinator) verifyRemotely(url string, request []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(request))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.Verification.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Verification.Token)
+	}
+	client := http.Client{Timeout: time.Duration(c.config.Verification.Timeout) * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.New("unreachable")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("HTTP %v", resp.StatusCode)
+	}
+	var response towersofpau.VerificationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.New("invalid response")
+	}
+	if !response.Valid {
+		return errors.New(response.Reason)
+	}
+	return nil
+}
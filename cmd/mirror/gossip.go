@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// Mirrors and participants exchange signed checkpoints of the history the coordinator showed them.
+// Conflicting checkpoints prove that the coordinator shows different histories to different parties.
+
+// errNotSynced is returned for checkpoints received before the key of the coordinator is known
+var errNotSynced = errors.New("not synced yet")
+
+// checkpoint signs the latest verified state of the mirror.
+func (m *Mirror) checkpoint() (*towersofpau.SignedCheckpoint, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if m.treeHead == nil {
+		return nil, errNotSynced
+	}
+	size := m.treeHead.TreeHead.Size
+	var hash string
+	if size > 0 {
+		hash = m.log[size-1].Entry.CeremonyHash
+	}
+	return towersofpau.SignCheckpoint(towersofpau.Checkpoint{
+		Ceremony:     m.ceremonyID,
+		Count:        size,
+		CeremonyHash: hash,
+		TreeHead:     *m.treeHead,
+		Timestamp:    time.Now().Unix(),
+	}, m.key)
+}
+
+// checkCheckpoint compares the checkpoint of another observer with the history seen by the mirror
+// and raises an alert on a split view. It returns an error if the checkpoint itself is invalid.
+// If catchUp is set, the mirror syncs first if the checkpoint is ahead of it. Checkpoints posted by
+// anyone must not make us sync, only those of our peers do.
+func (m *Mirror) checkCheckpoint(theirs *towersofpau.SignedCheckpoint, catchUp bool) error {
+	m.mutex.RLock()
+	key := m.coordinatorKey
+	size := len(m.leaves)
+	m.mutex.RUnlock()
+	if key == nil {
+		// Without the key of the coordinator any tree head would be accepted
+		return errNotSynced
+	}
+	if err := theirs.Verify(key); err != nil {
+		return err
+	}
+	if theirs.Checkpoint.Ceremony != m.ceremonyID {
+		return fmt.Errorf("checkpoint of ceremony %v", theirs.Checkpoint.Ceremony)
+	}
+	if catchUp && theirs.Checkpoint.Count > size {
+		// Catch up before comparing, the other observer may just be ahead of us
+		if err := m.sync(); err != nil {
+			fmt.Printf("Unable to sync: %v\n", err)
+		}
+	}
+
+	m.mutex.RLock()
+	count := theirs.Checkpoint.Count
+	if count <= len(m.leaves) {
+		var hash string
+		if count > 0 {
+			hash = m.log[count-1].Entry.CeremonyHash
+		}
+		root := towersofpau.EncodeHashes([][32]byte{towersofpau.MerkleRoot(m.leaves[:count])})[0]
+		m.mutex.RUnlock()
+		if hash != theirs.Checkpoint.CeremonyHash || root != theirs.Checkpoint.TreeHead.TreeHead.RootHash {
+			m.alert(fmt.Sprintf("split view: history of %v contributions differs from ours", count), theirs)
+		}
+		return nil
+	}
+	m.mutex.RUnlock()
+
+	// The coordinator showed them a longer history than us, it has to prove that ours is a prefix
+	ours, err := m.checkpoint()
+	if err != nil {
+		return nil
+	}
+	var proof towersofpau.LogConsistencyProof
+	err = m.getJSON(fmt.Sprintf("/tree/consistency?first=%v&second=%v", ours.Checkpoint.Count, count), &proof)
+	if err != nil {
+		m.alert(fmt.Sprintf("coordinator can not prove consistency with a history of %v contributions: %v", count, err), theirs)
+		return nil
+	}
+	hashes, err := towersofpau.DecodeHashes(proof.Hashes)
+	if err == nil {
+		err = towersofpau.ConsistentCheckpoints(&ours.Checkpoint, &theirs.Checkpoint, hashes)
+	}
+	if err != nil {
+		m.alert(err.Error(), theirs)
+	}
+	return nil
+}
+
+// alert records the misbehavior of the coordinator.
+func (m *Mirror) alert(reason string, checkpoint *towersofpau.SignedCheckpoint) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.alertLocked(reason, checkpoint)
+}
+
+// alertLocked records the misbehavior of the coordinator, mutex has to be held.
+func (m *Mirror) alertLocked(reason string, checkpoint *towersofpau.SignedCheckpoint) {
+	for _, alert := range m.alerts {
+		if checkpoint == nil && alert.Checkpoint == nil && alert.Reason == reason {
+			// Problems found on every sync are only reported once
+			return
+		}
+	}
+	fmt.Printf("ALERT: %v\n", reason)
+	m.alerts = append(m.alerts, towersofpau.Alert{
+		Time:       time.Now().Unix(),
+		Reason:     reason,
+		Checkpoint: checkpoint,
+	})
+}
+
+// gossip exchanges checkpoints with the peers.
+func (m *Mirror) gossip() {
+	ours, err := m.checkpoint()
+	if err != nil {
+		return
+	}
+	body, err := json.Marshal(ours)
+	if err != nil {
+		return
+	}
+	for _, peer := range m.peers {
+		resp, err := http.Post(peer+"/checkpoint", "application/json", bytes.NewReader(body))
+		if err != nil {
+			fmt.Printf("Unable to reach peer %v: %v\n", peer, err)
+			continue
+		}
+		var theirs towersofpau.SignedCheckpoint
+		err = json.NewDecoder(resp.Body).Decode(&theirs)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != 200 {
+			fmt.Printf("Invalid checkpoint from peer %v\n", peer)
+			continue
+		}
+		if err := m.checkCheckpoint(&theirs, true); err != nil {
+			fmt.Printf("Invalid checkpoint from peer %v: %v\n", peer, err)
+		}
+	}
+}
+
+// GetCheckpoint serves the latest checkpoint of the mirror.
+func (m *Mirror) GetCheckpoint(rw http.ResponseWriter, req *http.Request) {
+	ours, err := m.checkpoint()
+	if err != nil {
+		http.Error(rw, err.Error(), 503)
+		return
+	}
+	writeJSON(rw, ours)
+}
+
+// ExchangeCheckpoint checks the checkpoint of another observer and answers with the checkpoint of the mirror.
+func (m *Mirror) ExchangeCheckpoint(rw http.ResponseWriter, req *http.Request) {
+	var theirs towersofpau.SignedCheckpoint
+	if err := json.NewDecoder(req.Body).Decode(&theirs); err != nil {
+		http.Error(rw, "invalid checkpoint", 400)
+		return
+	}
+	if err := m.checkCheckpoint(&theirs, false); err == errNotSynced {
+		http.Error(rw, err.Error(), 503)
+		return
+	} else if err != nil {
+		http.Error(rw, err.Error(), 400)
+		return
+	}
+	m.GetCheckpoint(rw, req)
+}
+
+// Alerts serves the alerts raised so far.
+func (m *Mirror) Alerts(rw http.ResponseWriter, req *http.Request) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	writeJSON(rw, m.alerts)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	addr := flag.String("addr", ":2018", "address to serve the mirror on")
+	dir := flag.String("dir", "mirror", "directory the verified ceremonies are stored in")
+	ceremonyID := flag.String("ceremony", "main", "id of the ceremony to mirror")
+	coordinatorKeyHex := flag.String("coordinator-key", "", "hex encoded ed25519 public key the coordinator signs with, taken from the log if empty")
+	keyHex := flag.String("key", "", "hex encoded ed25519 seed the checkpoints are signed with, an ephemeral key is used if empty")
+	peers := flag.String("peers", "", "comma separated URLs of other mirrors to exchange checkpoints with")
+	interval := flag.Duration("interval", 10*time.Second, "interval between syncs with the coordinator and the peers")
+	requireBLSSignatures := flag.Bool("require-bls-signatures", false, "contributions with an identity have to be signed by it, has to match the coordinator")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		log.Fatal("invalid amount of args, need coordinator url and path to initial ceremony")
+	}
+	var coordinatorKey ed25519.PublicKey
+	if *coordinatorKeyHex != "" {
+		coordinatorKey = common.FromHex(*coordinatorKeyHex)
+		if len(coordinatorKey) != ed25519.PublicKeySize {
+			log.Fatal("invalid coordinator key")
+		}
+	}
+	var key ed25519.PrivateKey
+	if *keyHex != "" {
+		seed := common.FromHex(*keyHex)
+		if len(seed) != ed25519.SeedSize {
+			log.Fatal("key has to be a 32 byte ed25519 seed")
+		}
+		key = ed25519.NewKeyFromSeed(seed)
+	} else {
+		var err error
+		if _, key, err = ed25519.GenerateKey(nil); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("Signing checkpoints with key 0x%x\n", key.Public())
+	initial, err := os.ReadFile(flag.Arg(1))
+	if err != nil {
+		log.Fatal("unable to read initial ceremony ", err.Error())
+	}
+	url := fmt.Sprintf("%v/ceremonies/%v", flag.Arg(0), *ceremonyID)
+	mirror, err := NewMirror(url, *ceremonyID, *dir, initial, coordinatorKey, key)
+	if err != nil {
+		log.Fatal("unable to start mirror ", err.Error())
+	}
+	mirror.requireBLSSignatures = *requireBLSSignatures
+	if *peers != "" {
+		mirror.peers = strings.Split(*peers, ",")
+	}
+	go func() {
+		for {
+			if err := mirror.sync(); err != nil {
+				fmt.Printf("Unable to sync: %v\n", err)
+			}
+			mirror.gossip()
+			time.Sleep(*interval)
+		}
+	}()
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/ceremony/current", mirror.CurrentCeremony).
+		Methods("GET")
+	router.HandleFunc("/history", mirror.History).
+		Methods("GET")
+	router.HandleFunc("/history/{index:[0-9]+}", mirror.HistoryEntry).
+		Methods("GET")
+	router.HandleFunc("/log", mirror.Log).
+		Methods("GET")
+	router.HandleFunc("/tree/head", mirror.TreeHead).
+		Methods("GET")
+	router.HandleFunc("/tree/inclusion", mirror.InclusionProof).
+		Methods("GET")
+	router.HandleFunc("/tree/consistency", mirror.ConsistencyProof).
+		Methods("GET")
+	router.HandleFunc("/checkpoint", mirror.GetCheckpoint).
+		Methods("GET")
+	router.HandleFunc("/checkpoint", mirror.ExchangeCheckpoint).
+		Methods("POST")
+	router.HandleFunc("/alerts", mirror.Alerts).
+		Methods("GET")
+	log.Fatal(http.ListenAndServe(*addr, router))
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Mirror follows the history of a coordinator, verifies every contribution and republishes it.
+type Mirror struct {
+	// url of the coordinator including the ceremony prefix
+	url        string
+	ceremonyID string
+	dir        string
+	// requireBLSSignatures has to match the config of the coordinator
+	requireBLSSignatures bool
+	// coordinatorKey is pinned on the command line or taken from the first log entry
+	coordinatorKey ed25519.PublicKey
+	// key signs the checkpoints of the mirror
+	key   ed25519.PrivateKey
+	peers []string
+
+	// syncMutex serializes syncs with the coordinator
+	syncMutex sync.Mutex
+	mutex     sync.RWMutex
+	// pubkeys are the pot pubkeys of the verified contributions, a pubkey can only be used once
+	pubkeys *towersofpau.PubkeyIndex
+	// ceremony is the ceremony after the last verified contribution
+	ceremony *towersofpau.Ceremony
+	current  []byte
+	log      []towersofpau.SignedLogEntry
+	history  []towersofpau.Contribution
+	leaves   [][32]byte
+	// treeHead is the latest tree head of the coordinator that matches the verified log
+	treeHead *towersofpau.SignedTreeHead
+	alerts   []towersofpau.Alert
+}
+
+func NewMirror(url, ceremonyID, dir string, initial []byte, coordinatorKey ed25519.PublicKey, key ed25519.PrivateKey) (*Mirror, error) {
+	ceremony, err := towersofpau.Deserialize(bytes.NewReader(initial))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Mirror{
+		url:            url,
+		ceremonyID:     ceremonyID,
+		dir:            dir,
+		coordinatorKey: coordinatorKey,
+		key:            key,
+		pubkeys:        towersofpau.NewPubkeyIndex(ceremony),
+		ceremony:       ceremony,
+		current:        initial,
+		log:            make([]towersofpau.SignedLogEntry, 0),
+		history:        make([]towersofpau.Contribution, 0),
+		alerts:         make([]towersofpau.Alert, 0),
+	}, nil
+}
+
+// sync fetches and verifies the contributions that were accepted since the last sync.
+// Invalid contributions are not mirrored and raise an alert.
+func (m *Mirror) sync() error {
+	m.syncMutex.Lock()
+	defer m.syncMutex.Unlock()
+	var head towersofpau.SignedTreeHead
+	if err := m.getJSON("/tree/head", &head); err != nil {
+		return err
+	}
+	m.mutex.RLock()
+	from := len(m.log)
+	m.mutex.RUnlock()
+	var entries []towersofpau.SignedLogEntry
+	if err := m.getJSON(fmt.Sprintf("/log?from=%v", from), &entries); err != nil {
+		return err
+	}
+	for i := range entries {
+		if err := m.append(&entries[i]); err != nil {
+			m.alert(fmt.Sprintf("contribution %v: %v", from+i, err), nil)
+			return err
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if err := head.Verify(m.coordinatorKey); err != nil {
+		return err
+	}
+	if m.treeHead != nil && head.TreeHead.Size < m.treeHead.TreeHead.Size {
+		m.alertLocked(fmt.Sprintf("tree of the coordinator shrank from %v to %v entries", m.treeHead.TreeHead.Size, head.TreeHead.Size), nil)
+		return errors.New("tree head is older than the last one")
+	}
+	if head.TreeHead.Size > len(m.leaves) {
+		// The log grew after we fetched the tree head, check it on the next sync
+		return nil
+	}
+	root, err := head.TreeHead.Root()
+	if err != nil {
+		return err
+	}
+	if towersofpau.MerkleRoot(m.leaves[:head.TreeHead.Size]) != root {
+		m.alertLocked("tree head of the coordinator does not match its log", nil)
+		return errors.New("tree head does not match the log")
+	}
+	m.treeHead = &head
+	return nil
+}
+
+// append verifies the log entry and the contribution it describes and adds both to the mirror.
+func (m *Mirror) append(signed *towersofpau.SignedLogEntry) (err error) {
+	defer func() {
+		// The checks of the library may panic on malformed data, a malicious coordinator must only raise an alert
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid contribution: %v", r)
+		}
+	}()
+	m.mutex.RLock()
+	index := len(m.log)
+	var prevHash string
+	if index > 0 {
+		var err error
+		if prevHash, err = m.log[index-1].Entry.Hash(); err != nil {
+			m.mutex.RUnlock()
+			return err
+		}
+	}
+	prev := m.ceremony
+	key := m.coordinatorKey
+	m.mutex.RUnlock()
+
+	entry := signed.Entry
+	if err := signed.Verify(key); err != nil {
+		return err
+	}
+	if entry.Index != index || entry.PrevHash != prevHash {
+		return errors.New("log entry does not extend the log")
+	}
+	if entry.Ceremony != m.ceremonyID {
+		return fmt.Errorf("log entry belongs to ceremony %v", entry.Ceremony)
+	}
+	serialized, err := m.get(fmt.Sprintf("/history/%d", index))
+	if err != nil {
+		return err
+	}
+	next, err := towersofpau.Deserialize(bytes.NewReader(serialized))
+	if err != nil {
+		return err
+	}
+	root, err := next.HashTreeRoot()
+	if err != nil || "0x"+hex.EncodeToString(root[:]) != entry.CeremonyHash {
+		return errors.New("published ceremony does not match the log")
+	}
+	if err := m.verifyContribution(prev, next, &entry); err != nil {
+		return err
+	}
+	leaf, err := entry.LeafHash()
+	if err != nil {
+		return err
+	}
+	if err := m.pubkeys.Add(entry.PotPubkeys); err != nil {
+		return err
+	}
+	// The file is served as mirrored history, so it is only written once the contribution is accepted
+	if err := os.WriteFile(m.historyFile(index), serialized, 0644); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if key == nil {
+		// Every following entry has to be signed by the same key
+		m.coordinatorKey = common.FromHex(signed.PublicKey)
+	}
+	m.ceremony = next
+	m.current = serialized
+	m.log = append(m.log, *signed)
+	m.leaves = append(m.leaves, leaf)
+	m.history = append(m.history, towersofpau.Contribution{
+		Index:        entry.Index,
+		Slot:         entry.Slot,
+		Timestamp:    entry.Timestamp,
+		Identity:     entry.Identity,
+		Transcript:   entry.Transcript,
+		PotPubkeys:   entry.PotPubkeys,
+		CeremonyHash: entry.CeremonyHash,
+		Beacon:       entry.Beacon,
+	})
+	fmt.Printf("Verified contribution %v\n", index)
+	return nil
+}
+
+// verifyContribution checks that next is a valid contribution on top of prev as described by entry.
+func (m *Mirror) verifyContribution(prev, next *towersofpau.Ceremony, entry *towersofpau.LogEntry) error {
+	var identity string
+	if m.requireBLSSignatures {
+		identity = entry.Identity
+	}
+	pubkeys := next.LatestPotPubkeys()
+	if entry.Beacon != nil {
+		// Anyone can recompute the beacon contribution
+		if entry.Transcript != nil || entry.Identity != entry.Beacon.Identity() {
+			return errors.New("invalid beacon entry")
+		}
+		if err := towersofpau.VerifyBeacon(prev, next, *entry.Beacon); err != nil {
+			return err
+		}
+	} else if entry.Transcript == nil {
+		if err := towersofpau.VerifySubmission(prev, next, identity); err != nil {
+			return err
+		}
+	} else {
+		// Pipelined contributions only change a single transcript
+		t := *entry.Transcript
+		if len(prev.Transcripts) != len(next.Transcripts) || t < 0 || t >= len(next.Transcripts) {
+			return errors.New("invalid transcript index")
+		}
+		for i := range next.Transcripts {
+			if i == t {
+				continue
+			}
+			same, err := sameTranscript(prev, next, i)
+			if err != nil {
+				return err
+			}
+			if !same {
+				return fmt.Errorf("transcript %v changed in a contribution to transcript %v", i, t)
+			}
+		}
+		if err := towersofpau.VerifySubmission(prev.TranscriptCeremony(t), next.TranscriptCeremony(t), identity); err != nil {
+			return err
+		}
+		pubkeys = pubkeys[t : t+1]
+	}
+	if !reflect.DeepEqual(pubkeys, entry.PotPubkeys) {
+		return errors.New("pot pubkeys do not match the log")
+	}
+	return nil
+}
+
+func sameTranscript(a, b *towersofpau.Ceremony, index int) (bool, error) {
+	bufA, bufB := new(bytes.Buffer), new(bytes.Buffer)
+	if err := towersofpau.Serialize(bufA, a.TranscriptCeremony(index)); err != nil {
+		return false, err
+	}
+	if err := towersofpau.Serialize(bufB, b.TranscriptCeremony(index)); err != nil {
+		return false, err
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes()), nil
+}
+
+func (m *Mirror) historyFile(index int) string {
+	return filepath.Join(m.dir, fmt.Sprintf("%d.json", index))
+}
+
+func (m *Mirror) get(path string) ([]byte, error) {
+	resp, err := http.Get(m.url + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%v: HTTP %v", path, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (m *Mirror) getJSON(path string, v interface{}) error {
+	body, err := m.get(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
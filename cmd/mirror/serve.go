@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/gorilla/mux"
+)
+
+// historyPageSize matches the page size of the coordinator
+const historyPageSize = 100
+
+// The mirror serves the same read-only endpoints as the coordinator, from its verified copy.
+
+// CurrentCeremony serves the ceremony after the last verified contribution.
+func (m *Mirror) CurrentCeremony(rw http.ResponseWriter, req *http.Request) {
+	m.mutex.RLock()
+	current := m.current
+	m.mutex.RUnlock()
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(current)
+}
+
+// History serves a page of the verified contributions.
+func (m *Mirror) History(rw http.ResponseWriter, req *http.Request) {
+	page, ok := queryInt(req.URL.Query().Get("page"), 0)
+	if !ok {
+		http.Error(rw, "invalid page", 400)
+		return
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	from, to := page*historyPageSize, (page+1)*historyPageSize
+	if from > len(m.history) {
+		from = len(m.history)
+	}
+	if to > len(m.history) {
+		to = len(m.history)
+	}
+	writeJSON(rw, towersofpau.HistoryPage{
+		Page:          page,
+		PageSize:      historyPageSize,
+		Total:         len(m.history),
+		Contributions: m.history[from:to],
+	})
+}
+
+// HistoryEntry serves the ceremony after the contribution with the index.
+func (m *Mirror) HistoryEntry(rw http.ResponseWriter, req *http.Request) {
+	index, err := strconv.Atoi(mux.Vars(req)["index"])
+	m.mutex.RLock()
+	size := len(m.history)
+	m.mutex.RUnlock()
+	if err != nil || index < 0 || index >= size {
+		rw.WriteHeader(404)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	http.ServeFile(rw, req, m.historyFile(index))
+}
+
+// Log serves the verified log entries, starting at the index given by the from parameter.
+func (m *Mirror) Log(rw http.ResponseWriter, req *http.Request) {
+	from, ok := queryInt(req.URL.Query().Get("from"), 0)
+	if !ok {
+		http.Error(rw, "invalid from", 400)
+		return
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if from > len(m.log) {
+		from = len(m.log)
+	}
+	writeJSON(rw, m.log[from:])
+}
+
+// TreeHead serves the latest tree head of the coordinator that matches the verified log.
+func (m *Mirror) TreeHead(rw http.ResponseWriter, req *http.Request) {
+	m.mutex.RLock()
+	head := m.treeHead
+	m.mutex.RUnlock()
+	if head == nil {
+		http.Error(rw, "not synced yet", 503)
+		return
+	}
+	writeJSON(rw, head)
+}
+
+// InclusionProof serves the inclusion proof of the entry given by its index in the tree of the given size.
+func (m *Mirror) InclusionProof(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	size, ok := queryInt(query.Get("size"), len(m.leaves))
+	if !ok || size > len(m.leaves) {
+		http.Error(rw, "invalid size", 400)
+		return
+	}
+	index := -1
+	hash := query.Get("hash")
+	for i := range m.log[:size] {
+		if hash != "" && m.log[i].Entry.CeremonyHash == hash {
+			index = i
+			break
+		}
+	}
+	if hash == "" {
+		if index, ok = queryInt(query.Get("index"), -1); !ok {
+			index = -1
+		}
+	}
+	if index < 0 || index >= size {
+		rw.WriteHeader(404)
+		return
+	}
+	writeJSON(rw, towersofpau.LogInclusionProof{
+		TreeSize: size,
+		Index:    index,
+		Entry:    m.log[index].Entry,
+		Hashes:   towersofpau.EncodeHashes(towersofpau.InclusionProof(m.leaves[:size], index)),
+	})
+}
+
+// ConsistencyProof serves the proof that the tree of size first is a prefix of the tree of size second.
+func (m *Mirror) ConsistencyProof(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	second, ok := queryInt(query.Get("second"), len(m.leaves))
+	if !ok || second > len(m.leaves) {
+		http.Error(rw, "invalid second", 400)
+		return
+	}
+	first, ok := queryInt(query.Get("first"), -1)
+	if !ok || first < 0 || first > second {
+		http.Error(rw, "invalid first", 400)
+		return
+	}
+	writeJSON(rw, towersofpau.LogConsistencyProof{
+		First:  first,
+		Second: second,
+		Hashes: towersofpau.EncodeHashes(towersofpau.ConsistencyProof(m.leaves[:second], first)),
+	})
+}
+
+// queryInt parses a non-negative query parameter, def is returned if the parameter is missing.
+func queryInt(value string, def int) (int, bool) {
+	if value == "" {
+		return def, true
+	}
+	i, err := strconv.Atoi(value)
+	return i, err == nil && i >= 0
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		rw.WriteHeader(500)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(resp)
+}
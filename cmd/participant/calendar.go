@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// parseWindow parses a window given as two RFC 3339 timestamps separated by a slash,
+// e.g. 2022-09-01T14:00:00Z/2022-09-01T15:00:00Z
+func parseWindow(s string) (*towersofpau.TimeWindow, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return nil, errors.New("window has to be of the form start/end")
+	}
+	start, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if !end.After(start) {
+		return nil, errors.New("window ends before it starts")
+	}
+	return &towersofpau.TimeWindow{Start: start.Unix(), End: end.Unix()}, nil
+}
+
+// formatWindows prints the alternatives the coordinator offered for a window that was already booked.
+func formatWindows(windows []towersofpau.TimeWindow) string {
+	var b strings.Builder
+	for _, window := range windows {
+		start := time.Unix(window.Start, 0).UTC().Format(time.RFC3339)
+		if window.End == 0 {
+			fmt.Fprintf(&b, "\n  from %v on", start)
+		} else {
+			fmt.Fprintf(&b, "\n  %v/%v", start, time.Unix(window.End, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	return b.String()
+}
+
+// SaveCalendar stores the iCalendar event of our slot at path.
+func (c *Client) SaveCalendar(path string) error {
+	if c.registration == nil {
+		return errors.New("no registration available")
+	}
+	url := fmt.Sprintf("%v/%v/%v/calendar.ics", c.url, "participation", c.registration.Ticket)
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching calendar failed with status code %v", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Saved our slot to %v\n", path)
+	return nil
+}
@@ -2,32 +2,69 @@ package main
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
-func NewClient(url string) *Client {
+func NewClient(url string, key *ecdsa.PrivateKey, coordinatorKey ed25519.PublicKey) *Client {
 	return &Client{
-		url: url,
+		url:            url,
+		key:            key,
+		coordinatorKey: coordinatorKey,
 	}
 }
 
 type Client struct {
-	url          string
+	url string
+	key *ecdsa.PrivateKey
+	// coordinatorKey is the expected signer of receipts, any key is accepted if nil
+	coordinatorKey ed25519.PublicKey
+	// benchmark is sent to the coordinator to size our slot
+	benchmark *towersofpau.ContributionBenchmark
+	// ceremony is the id of the ceremony we contribute to, receipts of other ceremonies are rejected if set
+	ceremony string
+	// invite is sent to coordinators that only let allowlisted participants register
+	invite string
+	// window is the time our slot has to lie in, the next free slot is taken if nil
+	window *towersofpau.TimeWindow
+	// checkInclusion verifies that our accepted contributions are included in the log of the coordinator
+	checkInclusion bool
+	// verifyLineage checks that the received ceremony extends the history we know before we contribute
+	verifyLineage bool
+	// detectionProbability is the probability with which we detect a single invalid pairing relation
+	// of the received ceremony before contributing
+	detectionProbability float64
+	// mirrors are the mirrors we compare the history the coordinator showed us with
+	mirrors      []string
 	registration *registration
+
+	queueMutex sync.Mutex
+	queue      towersofpau.QueueInfo
+	// clockOffset is the difference between our clock and the clock of the coordinator in seconds
+	clockOffset int64
 }
 
 type registration struct {
 	Start    int
 	Deadline int
 	Ticket   string
+	Identity string
 }
 
 func (c *Client) StartTime() *time.Time {
@@ -38,13 +75,64 @@ func (c *Client) StartTime() *time.Time {
 	return &t
 }
 
+// Identity returns the identity our ticket is bound to.
+func (c *Client) Identity() string {
+	if c.registration == nil {
+		return ""
+	}
+	return c.registration.Identity
+}
+
 func (c *Client) Register() error {
 	fmt.Println("Registering for ceremony")
 	url := fmt.Sprintf("%v/%v", c.url, "participation")
-	var body io.Reader
-	resp, err := http.Post(url, "text/html; charset=UTF-8", body)
-	if err != nil {
-		return err
+	var resp *http.Response
+	for {
+		request, err := c.solveChallenge()
+		if err != nil {
+			return err
+		}
+		if c.key != nil {
+			if err := c.signIn(request); err != nil {
+				return err
+			}
+		}
+		request.Benchmark = c.benchmark
+		request.Invite = c.invite
+		if c.window != nil {
+			request.WindowStart = c.window.Start
+			request.WindowEnd = c.window.End
+		}
+		body, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		resp, err = http.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+		reason, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		wait, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			return fmt.Errorf("registration rejected: %v", strings.TrimSpace(string(reason)))
+		}
+		fmt.Printf("Registration rejected (%v), retrying in %v seconds\n", strings.TrimSpace(string(reason)), wait)
+		time.Sleep(time.Duration(wait) * time.Second)
+	}
+	if resp.StatusCode == http.StatusConflict && resp.Header.Get("Content-Type") == "application/json" {
+		var conflict towersofpau.BookingConflict
+		if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+			return err
+		}
+		return fmt.Errorf("registration failed: %v, available windows:%v", conflict.Reason, formatWindows(conflict.Alternatives))
+	}
+	if resp.StatusCode != http.StatusOK {
+		reason, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("registration failed with status code %v: %v", resp.StatusCode, strings.TrimSpace(string(reason)))
 	}
 	responseData, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -55,14 +143,88 @@ func (c *Client) Register() error {
 		return err
 	}
 	c.registration = &part
-	fmt.Printf("Registered for ceremony at time %v\n", time.Unix(int64(part.Start), 0))
+	if part.Identity != "" {
+		fmt.Printf("Registered as %v\n", part.Identity)
+	}
+	fmt.Printf("Registered for ceremony at time %v, deadline %v\n", time.Unix(int64(part.Start), 0), time.Unix(int64(part.Deadline), 0))
+	return nil
+}
+
+// signIn signs the sign-in with ethereum message of the coordinator with our key.
+func (c *Client) signIn(request *towersofpau.RegistrationRequest) error {
+	address := crypto.PubkeyToAddress(c.key.PublicKey)
+	url := fmt.Sprintf("%v/%v?address=%v", c.url, "participation/signin", address.Hex())
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching sign-in failed with status code %v", resp.StatusCode)
+	}
+	var challenge towersofpau.SignInChallenge
+	if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+		return err
+	}
+	if err := c.checkSignInDomain(challenge.Message); err != nil {
+		return err
+	}
+	fmt.Printf("Signing in with ethereum:\n%v\n", challenge.Message)
+	signature, err := towersofpau.SignSIWE(challenge.Message, c.key)
+	if err != nil {
+		return err
+	}
+	request.SignInNonce = challenge.Nonce
+	request.Address = address.Hex()
+	request.Signature = hexutil.Encode(signature)
 	return nil
 }
 
+// checkSignInDomain checks that the sign-in message is for the coordinator we talk to,
+// otherwise our signature could be used to register with another one.
+func (c *Client) checkSignInDomain(message string) error {
+	coordinator, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(message, coordinator.Host+" wants you to sign in") {
+		return fmt.Errorf("sign-in message is not for %v", coordinator.Host)
+	}
+	return nil
+}
+
+// solveChallenge fetches a proof-of-work challenge from the coordinator and solves it.
+func (c *Client) solveChallenge() (*towersofpau.RegistrationRequest, error) {
+	url := fmt.Sprintf("%v/%v", c.url, "participation/challenge")
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching challenge failed with status code %v", resp.StatusCode)
+	}
+	var challenge towersofpau.Challenge
+	if err := json.NewDecoder(resp.Body).Decode(&challenge); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Solving registration challenge with difficulty %v\n", challenge.Difficulty)
+	start := time.Now()
+	solution := towersofpau.SolveChallenge(common.Hex2Bytes(challenge.Nonce), challenge.Difficulty)
+	fmt.Printf("Challenge solved in %v\n", time.Since(start))
+	return &towersofpau.RegistrationRequest{
+		Nonce:    challenge.Nonce,
+		Solution: solution,
+	}, nil
+}
+
 type Info struct {
-	Start    int
-	Deadline int
-	Ceremony *towersofpau.JSONCeremony
+	Start           int
+	Deadline        int
+	Ceremony        *towersofpau.JSONCeremony
+	Transcript      *int
+	TranscriptCount int
+	towersofpau.QueueInfo
 }
 
 func (c *Client) GetCeremony() (*Info, error) {
@@ -86,6 +248,7 @@ func (c *Client) GetCeremony() (*Info, error) {
 
 	c.registration.Start = info.Start
 	c.registration.Deadline = info.Deadline
+	c.updateQueue(info.QueueInfo)
 
 	fmt.Println("Retrieved ceremony")
 	return &info, nil
@@ -97,7 +260,21 @@ func (c *Client) SubmitCeremony(ceremony *towersofpau.Ceremony) error {
 		return errors.New("no registration available")
 	}
 	url := fmt.Sprintf("%v/%v/%v", c.url, "participation", c.registration.Ticket)
+	return c.submit(url, ceremony)
+}
+
+// SubmitTranscript submits our contribution to a single transcript to a pipelined coordinator,
+// ceremony only contains the transcript.
+func (c *Client) SubmitTranscript(index int, ceremony *towersofpau.Ceremony) error {
+	fmt.Printf("Submitting transcript %v\n", index)
+	if c.registration == nil {
+		return errors.New("no registration available")
+	}
+	url := fmt.Sprintf("%v/%v/%v/transcripts/%v", c.url, "participation", c.registration.Ticket, index)
+	return c.submit(url, ceremony)
+}
 
+func (c *Client) submit(url string, ceremony *towersofpau.Ceremony) error {
 	buf := new(bytes.Buffer)
 	towersofpau.Serialize(buf, ceremony)
 	resp, err := http.Post(url, "text/html", buf)
@@ -105,13 +282,78 @@ func (c *Client) SubmitCeremony(ceremony *towersofpau.Ceremony) error {
 		return err
 	}
 	switch resp.StatusCode {
-	case 200:
+	case 200, 400:
+		receipt, err := c.saveReceipt(resp.Body)
+		if err != nil {
+			return err
+		}
+		if !receipt.Receipt.Accepted {
+			return fmt.Errorf("invalid ceremony: %v", receipt.Receipt.Reason)
+		}
+		// A pipelined receipt names the whole ceremony, which we don't know
+		if receipt.Receipt.Transcript == nil {
+			root, err := ceremony.HashTreeRoot()
+			if err != nil {
+				return err
+			}
+			if hash := hexutil.Encode(root[:]); hash != receipt.Receipt.CeremonyHash {
+				return fmt.Errorf("receipt is for ceremony %v, we submitted %v", receipt.Receipt.CeremonyHash, hash)
+			}
+		}
 		fmt.Println("Submitted ceremony successfully")
-		return nil
-	case 400:
-		return errors.New("invalid ceremony")
+		if err := c.saveLineage(ceremony, receipt.Receipt.Transcript, receipt.Receipt.PotPubkeys); err != nil {
+			fmt.Printf("Unable to save the lineage: %v\n", err)
+		}
+		if !c.checkInclusion {
+			return nil
+		}
+		head, err := c.CheckInclusion(receipt)
+		if err != nil {
+			return err
+		}
+		return c.Gossip(head)
 	case 403:
 		return errors.New("invalid ticket provided")
 	}
 	return errors.New("invalid status code")
 }
+
+// saveReceipt verifies the receipt of our submission and stores it,
+// it allows us to prove what the coordinator answered to our submission.
+func (c *Client) saveReceipt(body io.Reader) (*towersofpau.SignedReceipt, error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	var receipt towersofpau.SignedReceipt
+	if err := json.Unmarshal(data, &receipt); err != nil {
+		return nil, err
+	}
+	if err := receipt.Verify(c.coordinatorKey); err != nil {
+		return nil, fmt.Errorf("invalid receipt: %v", err)
+	}
+	if c.ceremony != "" && receipt.Receipt.Ceremony != c.ceremony {
+		return nil, fmt.Errorf("receipt is for ceremony %q", receipt.Receipt.Ceremony)
+	}
+	if c.coordinatorKey == nil {
+		fmt.Printf("Receipt signed by unpinned coordinator key %v\n", receipt.PublicKey)
+	}
+	path := fmt.Sprintf("receipt-%d.json", receipt.Receipt.SlotIndex)
+	if receipt.Receipt.Transcript != nil {
+		path = fmt.Sprintf("receipt-%d-%d.json", receipt.Receipt.SlotIndex, *receipt.Receipt.Transcript)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Saved receipt to %v\n", path)
+	return &receipt, nil
+}
+
+// Requeued returns whether our ticket got a new slot after our submission was rejected.
+func (c *Client) Requeued() bool {
+	if c.registration == nil {
+		return false
+	}
+	info, err := c.GetCeremony()
+	return err == nil && info.Deadline > int(time.Now().Unix())
+}
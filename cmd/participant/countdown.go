@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// updateQueue stores the latest queue info sent by the coordinator.
+func (c *Client) updateQueue(info towersofpau.QueueInfo) {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	c.queue = info
+	c.clockOffset = time.Now().Unix() - info.ServerTime
+}
+
+// Countdown prints our place in the queue and the estimated time until our turn
+// every second until stop is closed.
+func (c *Client) Countdown(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			fmt.Println()
+			return
+		case <-ticker.C:
+		}
+		c.queueMutex.Lock()
+		queue, offset := c.queue, c.clockOffset
+		c.queueMutex.Unlock()
+		if queue.ServerTime == 0 {
+			continue
+		}
+		// Convert the estimate of the coordinator to our clock
+		remaining := time.Until(time.Unix(queue.EstimatedStart+offset, 0)).Round(time.Second)
+		if remaining < 0 {
+			remaining = 0
+		}
+		fmt.Printf("\rPosition %v in queue, %v participants ahead, estimated start in %v      ", queue.Position, queue.Ahead, remaining)
+	}
+}
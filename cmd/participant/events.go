@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// WaitForTurn follows the event stream of our ticket until our slot starts.
+func (c *Client) WaitForTurn() error {
+	if c.registration == nil {
+		return errors.New("no registration available")
+	}
+	url := fmt.Sprintf("%v/%v/%v/events", c.url, "participation", c.registration.Ticket)
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream failed with status code %v", resp.StatusCode)
+	}
+
+	var (
+		name    string
+		data    []string
+		scanner = bufio.NewScanner(resp.Body)
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, ":"):
+			// comment, used as keepalive
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		case line == "":
+			turn, err := c.handleEvent(name, []byte(strings.Join(data, "\n")))
+			if err != nil || turn {
+				return err
+			}
+			name, data = "", nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("event stream closed")
+}
+
+// handleEvent processes an event of the stream, it returns true once our turn started.
+func (c *Client) handleEvent(name string, data []byte) (bool, error) {
+	switch name {
+	case "position", "turn":
+		var position towersofpau.PositionEvent
+		if err := json.Unmarshal(data, &position); err != nil {
+			return false, err
+		}
+		c.registration.Start = int(position.Start)
+		c.registration.Deadline = int(position.Deadline)
+		c.updateQueue(position.QueueInfo)
+		if name == "turn" {
+			return true, nil
+		}
+	case "verification":
+		var verification towersofpau.VerificationEvent
+		if err := json.Unmarshal(data, &verification); err != nil {
+			return false, err
+		}
+		if !verification.Accepted {
+			fmt.Printf("Submission of slot %v rejected: %v\n", verification.Slot, verification.Reason)
+		}
+	case "contribution":
+		var contribution towersofpau.Contribution
+		if err := json.Unmarshal(data, &contribution); err != nil {
+			return false, err
+		}
+		fmt.Printf("Contribution %v accepted\n", contribution.Index)
+	}
+	return false, nil
+}
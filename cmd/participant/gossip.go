@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Gossip compares the history the coordinator showed us, as committed to by head, with the mirrors.
+// It returns an error if the coordinator showed a mirror a different history.
+func (c *Client) Gossip(head *towersofpau.SignedTreeHead) error {
+	if len(c.mirrors) == 0 || head.TreeHead.Size == 0 {
+		return nil
+	}
+	ours, err := c.checkpoint(head)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(ours)
+	if err != nil {
+		return err
+	}
+	key := c.coordinatorKey
+	if key == nil {
+		key = common.FromHex(head.PublicKey)
+	}
+	for _, mirror := range c.mirrors {
+		resp, err := http.Post(mirror+"/checkpoint", "application/json", bytes.NewReader(body))
+		if err != nil {
+			fmt.Printf("Unable to reach mirror %v: %v\n", mirror, err)
+			continue
+		}
+		var theirs towersofpau.SignedCheckpoint
+		err = json.NewDecoder(resp.Body).Decode(&theirs)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != 200 {
+			fmt.Printf("Mirror %v did not answer with a checkpoint\n", mirror)
+			continue
+		}
+		if err := theirs.Verify(key); err != nil {
+			fmt.Printf("Invalid checkpoint of mirror %v: %v\n", mirror, err)
+			continue
+		}
+		if err := c.compareCheckpoints(&ours.Checkpoint, &theirs.Checkpoint); err != nil {
+			return fmt.Errorf("mirror %v was shown a different history: %v", mirror, err)
+		}
+		fmt.Printf("Mirror %v was shown the same history\n", mirror)
+	}
+	return nil
+}
+
+// checkpoint signs the state of the history committed to by head with an ephemeral key.
+func (c *Client) checkpoint(head *towersofpau.SignedTreeHead) (*towersofpau.SignedCheckpoint, error) {
+	size := head.TreeHead.Size
+	var proof towersofpau.LogInclusionProof
+	if err := c.getJSON(fmt.Sprintf("/tree/inclusion?index=%v&size=%v", size-1, size), &proof); err != nil {
+		return nil, err
+	}
+	if err := verifyInclusionProof(head.TreeHead, proof); err != nil {
+		return nil, fmt.Errorf("invalid inclusion proof: %v", err)
+	}
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	return towersofpau.SignCheckpoint(towersofpau.Checkpoint{
+		Ceremony:     head.TreeHead.Ceremony,
+		Count:        size,
+		CeremonyHash: proof.Entry.CeremonyHash,
+		TreeHead:     *head,
+		Timestamp:    time.Now().Unix(),
+	}, key)
+}
+
+// compareCheckpoints checks that both checkpoints describe the same history, the coordinator has to
+// prove that the smaller one is a prefix of the larger one.
+func (c *Client) compareCheckpoints(ours, theirs *towersofpau.Checkpoint) error {
+	if ours.Count == theirs.Count {
+		return towersofpau.ConflictingCheckpoints(ours, theirs)
+	}
+	first, second := ours.Count, theirs.Count
+	if first > second {
+		first, second = second, first
+	}
+	var proof towersofpau.LogConsistencyProof
+	if err := c.getJSON(fmt.Sprintf("/tree/consistency?first=%v&second=%v", first, second), &proof); err != nil {
+		return fmt.Errorf("no consistency proof: %v", err)
+	}
+	hashes, err := towersofpau.DecodeHashes(proof.Hashes)
+	if err != nil {
+		return err
+	}
+	return towersofpau.ConsistentCheckpoints(ours, theirs, hashes)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// heartbeatInterval has to be well below the heartbeat timeout of the coordinator
+const heartbeatInterval = 5 * time.Second
+
+// KeepAlive sends heartbeats to the coordinator until stop is closed,
+// otherwise the coordinator assumes that we crashed and releases our slot.
+func (c *Client) KeepAlive(stop chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := c.post("heartbeat"); err != nil {
+				fmt.Printf("Heartbeat failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// Abort gives up our slot, s.th. the next participant does not have to wait for our deadline.
+func (c *Client) Abort() error {
+	fmt.Println("Giving up our slot")
+	return c.post("abort")
+}
+
+func (c *Client) post(action string) error {
+	if c.registration == nil {
+		return errors.New("no registration available")
+	}
+	url := fmt.Sprintf("%v/%v/%v/%v", c.url, "participation", c.registration.Ticket, action)
+	resp, err := http.Post(url, "text/html", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return nil
+	case 403:
+		return errors.New("slot is no longer ours")
+	}
+	return errors.New("invalid status code")
+}
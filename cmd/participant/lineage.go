@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// lineagePath is the file the pot pubkeys of the ceremony after our last accepted contribution are saved to
+const lineagePath = "lineage.json"
+
+// lineage is what we saw of a ceremony, the next ceremony we receive has to extend it.
+type lineage struct {
+	// URL of the coordinator, lineages of other coordinators are ignored
+	URL string
+	// PotPubkeys of every transcript after our last accepted contribution to it
+	PotPubkeys [][]string
+	// Contributions are the pot pubkeys of our accepted contributions to every transcript
+	Contributions [][]string
+}
+
+// CheckLineage verifies the received ceremony before we contribute to it: its powers have to match its witness,
+// it has to extend the ceremony we saw last including our own contributions, and it has to contain the latest
+// contribution of the signed log of the coordinator. transcript is the index of the transcript a pipelined
+// coordinator handed out, nil if the ceremony contains all transcripts.
+func (c *Client) CheckLineage(ceremony *towersofpau.Ceremony, transcript *int) error {
+	if !towersofpau.PowersWitnessCheck(ceremony) {
+		return errors.New("powers of the received ceremony do not match its witness")
+	}
+	indices := transcriptIndices(ceremony, transcript)
+	received := potPubkeys(ceremony)
+	saved, err := c.loadLineage()
+	if err != nil {
+		return err
+	}
+	if saved != nil {
+		for k, t := range indices {
+			if t >= len(saved.PotPubkeys) {
+				continue
+			}
+			for _, pk := range saved.Contributions[t] {
+				if !containsString(received[k], pk) {
+					return fmt.Errorf("our contribution %v to transcript %v is missing", pk, t)
+				}
+			}
+			if !isPrefix(saved.PotPubkeys[t], received[k]) {
+				return fmt.Errorf("transcript %v does not extend the state we saw last", t)
+			}
+		}
+	}
+	if err := c.checkLatestEntry(received, indices); err != nil {
+		return err
+	}
+	fmt.Println("Received ceremony extends the history we know")
+	return nil
+}
+
+// checkLatestEntry checks that the latest contribution in the signed log of the coordinator is part of the
+// received transcripts.
+func (c *Client) checkLatestEntry(received [][]string, indices []int) error {
+	var head towersofpau.SignedTreeHead
+	if err := c.getJSON("/tree/head", &head); err != nil {
+		return err
+	}
+	if err := head.Verify(c.coordinatorKey); err != nil {
+		return fmt.Errorf("invalid tree head: %v", err)
+	}
+	size := head.TreeHead.Size
+	if size == 0 {
+		return nil
+	}
+	var proof towersofpau.LogInclusionProof
+	if err := c.getJSON(fmt.Sprintf("/tree/inclusion?index=%v&size=%v", size-1, size), &proof); err != nil {
+		return err
+	}
+	if err := verifyInclusionProof(head.TreeHead, proof); err != nil {
+		return fmt.Errorf("invalid inclusion proof: %v", err)
+	}
+	entry := proof.Entry
+	for k, t := range indices {
+		var pk string
+		if entry.Transcript == nil && t < len(entry.PotPubkeys) {
+			pk = entry.PotPubkeys[t]
+		} else if entry.Transcript != nil && *entry.Transcript == t && len(entry.PotPubkeys) == 1 {
+			pk = entry.PotPubkeys[0]
+		} else {
+			continue
+		}
+		if !containsString(received[k], pk) {
+			return fmt.Errorf("latest logged contribution %v is missing in transcript %v", entry.Index, t)
+		}
+	}
+	return nil
+}
+
+// saveLineage records the ceremony after our accepted contribution, pubkeys are the pot pubkeys of the contribution.
+func (c *Client) saveLineage(ceremony *towersofpau.Ceremony, transcript *int, pubkeys []string) error {
+	indices := transcriptIndices(ceremony, transcript)
+	if len(pubkeys) != len(indices) {
+		return errors.New("pot pubkeys do not match the transcripts")
+	}
+	saved, err := c.loadLineage()
+	if err != nil {
+		return err
+	}
+	if saved == nil {
+		saved = &lineage{URL: c.url}
+	}
+	received := potPubkeys(ceremony)
+	for k, t := range indices {
+		for len(saved.PotPubkeys) <= t {
+			saved.PotPubkeys = append(saved.PotPubkeys, nil)
+			saved.Contributions = append(saved.Contributions, nil)
+		}
+		saved.PotPubkeys[t] = received[k]
+		saved.Contributions[t] = append(saved.Contributions[t], pubkeys[k])
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(lineagePath, data, 0644)
+}
+
+// loadLineage returns the saved lineage of our coordinator, nil if there is none.
+func (c *Client) loadLineage() (*lineage, error) {
+	data, err := ioutil.ReadFile(lineagePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var saved lineage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("invalid %v: %v", lineagePath, err)
+	}
+	if saved.URL != c.url || len(saved.PotPubkeys) != len(saved.Contributions) {
+		return nil, nil
+	}
+	return &saved, nil
+}
+
+// transcriptIndices returns the index of every transcript of the ceremony within the full ceremony.
+func transcriptIndices(ceremony *towersofpau.Ceremony, transcript *int) []int {
+	if transcript != nil {
+		return []int{*transcript}
+	}
+	indices := make([]int, len(ceremony.Transcripts))
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// potPubkeys returns the hex encoded pot pubkeys of every transcript.
+func potPubkeys(ceremony *towersofpau.Ceremony) [][]string {
+	pubkeys := make([][]string, 0, len(ceremony.Transcripts))
+	for _, t := range ceremony.Transcripts {
+		encoded := make([]string, 0, len(t.Witness.PotPubkeys))
+		for _, pk := range t.Witness.PotPubkeys {
+			encoded = append(encoded, hexutil.Encode(pk.Compress()))
+		}
+		pubkeys = append(pubkeys, encoded)
+	}
+	return pubkeys
+}
+
+func containsString(list []string, s string) bool {
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isPrefix(prefix, list []string) bool {
+	if len(prefix) > len(list) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != list[i] {
+			return false
+		}
+	}
+	return true
+}
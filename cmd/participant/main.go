@@ -1,31 +1,135 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		panic("invalid amount of args, need 2")
+	keyPath := flag.String("key", "", "file containing a hex encoded ethereum private key to sign in with")
+	coordinatorKeyHex := flag.String("coordinator-key", "", "hex encoded ed25519 public key the coordinator signs receipts with")
+	ceremonyID := flag.String("ceremony", "", "id of the ceremony to contribute to, if the coordinator runs several")
+	invite := flag.String("invite", "", "invite code for closed registrations or the priority lane")
+	window := flag.String("window", "", "book a slot within start/end, given as RFC 3339 timestamps, and save it to slot.ics")
+	checkInclusion := flag.Bool("check-inclusion", true, "verify that our contribution is included in the signed log of the coordinator")
+	mirrors := flag.String("mirrors", "", "comma separated URLs of mirrors to compare the history of the coordinator with")
+	benchmark := flag.Bool("benchmark", true, "benchmark this machine, s.th. the coordinator can size our slot")
+	verifyLineage := flag.Bool("verify-lineage", true, "check that the received ceremony extends the history we know, including our earlier contributions")
+	detectionProbability := flag.Float64("detection-probability", 0.999999, "probability with which an invalid pairing relation of the received ceremony is detected before contributing, "+
+		"the relations are checked in random linear combinations with coefficients of -log2(1-p) bits, 1 checks every relation with its own pairing, 0 skips the check")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		panic("invalid amount of args, need coordinator url")
+	}
+	url := flag.Arg(0)
+	if *ceremonyID != "" {
+		url = fmt.Sprintf("%v/ceremonies/%v", url, *ceremonyID)
+	}
+	var key *ecdsa.PrivateKey
+	if *keyPath != "" {
+		var err error
+		key, err = crypto.LoadECDSA(*keyPath)
+		if err != nil {
+			panic(err)
+		}
+	}
+	var coordinatorKey ed25519.PublicKey
+	if *coordinatorKeyHex != "" {
+		coordinatorKey = common.FromHex(*coordinatorKeyHex)
+		if len(coordinatorKey) != ed25519.PublicKeySize {
+			panic("invalid coordinator key")
+		}
+	}
+	client := NewClient(url, key, coordinatorKey)
+	client.invite = *invite
+	client.ceremony = *ceremonyID
+	client.checkInclusion = *checkInclusion
+	if *detectionProbability < 0 || *detectionProbability > 1 {
+		panic("detection probability has to be between 0 and 1")
+	}
+	client.detectionProbability = *detectionProbability
+	client.verifyLineage = *verifyLineage
+	if *mirrors != "" {
+		client.mirrors = strings.Split(*mirrors, ",")
+	}
+	if *window != "" {
+		var err error
+		client.window, err = parseWindow(*window)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if *benchmark {
+		fmt.Println("Benchmarking this machine")
+		result := towersofpau.RunContributionBenchmark()
+		fmt.Printf("Scalar multiplication takes %v in G1 and %v in G2\n", time.Duration(result.G1Mult), time.Duration(result.G2Mult))
+		client.benchmark = &result
 	}
-	url := os.Args[1]
-	client := NewClient(url)
 	// Register with the coordinator
 	if err := client.Register(); err != nil {
 		panic(err)
 	}
+	if client.window != nil {
+		if err := client.SaveCalendar("slot.ics"); err != nil {
+			fmt.Printf("Unable to save our slot: %v\n", err)
+		}
+	}
+	// Give up our slot if we are interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		client.Abort()
+		os.Exit(1)
+	}()
+
+	for {
+		err := contribute(client)
+		if err == nil {
+			return
+		}
+		// Optimistic coordinators re-queue us if we contributed on top of a contribution that turned out to be invalid
+		if !client.Requeued() {
+			panic(err)
+		}
+		fmt.Printf("Our contribution was rolled back (%v), contributing again\n", err)
+	}
+}
+
+// contribute waits for our turn, contributes and submits the updated ceremony.
+func contribute(client *Client) error {
+	// Wait for the coordinator to tell us that our turn started
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		client.Countdown(stop)
+		close(done)
+	}()
+	err := client.WaitForTurn()
+	close(stop)
+	<-done
+	if err != nil {
+		fmt.Printf("Unable to follow events (%v), waiting for our start time instead\n", err)
+	} else {
+		fmt.Println("Our turn started")
+	}
 
 	var info *Info
 	for info == nil || info.Ceremony == nil {
 		// Retrieve our start time
 		start := client.StartTime()
 		if start == nil {
-			panic("invalid start time")
+			return errors.New("invalid start time")
 		}
 		// Wait for our start time
 		fmt.Printf("Waiting for our start time: %v\n", time.Until(*start))
@@ -34,35 +138,75 @@ func main() {
 		var err error
 		info, err = client.GetCeremony()
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
+	if info.Transcript != nil {
+		// The coordinator hands out the transcripts individually
+		if err := contributePipelined(client, info); err != nil {
+			client.Abort()
+			return err
+		}
+		return nil
+	}
+
 	ceremony, err := towersofpau.DeserializeJSONCeremony(*info.Ceremony)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// Participate
+	// Participate, the heartbeats tell the coordinator that we are still alive
+	stopHeartbeats := make(chan struct{})
+	go client.KeepAlive(stopHeartbeats)
+	if client.verifyLineage {
+		if err := client.CheckLineage(ceremony, nil); err != nil {
+			close(stopHeartbeats)
+			client.Abort()
+			return err
+		}
+	}
 	newCeremony := ceremony.Copy()
-	if err := participate(newCeremony); err != nil {
-		panic(err)
+	if err := participate(newCeremony, client.Identity(), client.detectionProbability); err != nil {
+		close(stopHeartbeats)
+		client.Abort()
+		return err
 	}
+	close(stopHeartbeats)
 	// Send reply
-	if err := client.SubmitCeremony(newCeremony); err != nil {
-		panic(err)
-	}
+	return client.SubmitCeremony(newCeremony)
 }
 
-func participate(ceremony *towersofpau.Ceremony) error {
+// participate adds our contribution to the ceremony after checking it, the pairing relations are checked
+// s.th. an invalid one is detected with the probability.
+func participate(ceremony *towersofpau.Ceremony, identity string, probability float64) error {
 	fmt.Println("Calculating our contribution")
 	start := time.Now()
 	// Verify the data
 	if !towersofpau.SubgroupChecksParticipant(ceremony) {
 		return errors.New("subgroup check failed")
 	}
+	if probability > 0 {
+		report, err := towersofpau.VerifyPairingSampled(ceremony, probability)
+		if err != nil {
+			return err
+		}
+		if !report.Valid {
+			return errors.New("pairing check of the received ceremony failed")
+		}
+		var relations int
+		for _, r := range report.Relations {
+			relations += r
+		}
+		if report.Bits == 0 {
+			fmt.Printf("Checked %v pairing relations in %v\n", relations, time.Since(start))
+		} else {
+			fmt.Printf("Checked %v pairing relations with %v bit coefficients in %v, an invalid relation is missed with probability at most 2^-%v\n",
+				relations, report.Bits, time.Since(start), report.Bits)
+		}
+	}
 	// Add our contribution
-	if err := towersofpau.UpdateTranscript(ceremony); err != nil {
+	if err := towersofpau.UpdateTranscript(ceremony, identity); err != nil {
 		return err
 	}
 	fmt.Printf("Contribution calculated in %v\n", time.Since(start))
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// transcriptPollInterval is how often a pipelined coordinator is asked whether our next transcript is available.
+const transcriptPollInterval = time.Second
+
+// contributePipelined contributes to the transcripts one after another as a pipelined coordinator hands them out,
+// info contains the first transcript.
+func contributePipelined(client *Client, info *Info) error {
+	stopHeartbeats := make(chan struct{})
+	go client.KeepAlive(stopHeartbeats)
+	defer close(stopHeartbeats)
+	for {
+		index := *info.Transcript
+		fmt.Printf("Contributing to transcript %v of %v\n", index+1, info.TranscriptCount)
+		ceremony, err := towersofpau.DeserializeJSONCeremony(*info.Ceremony)
+		if err != nil {
+			return err
+		}
+		if client.verifyLineage {
+			if err := client.CheckLineage(ceremony, &index); err != nil {
+				return err
+			}
+		}
+		if err := participate(ceremony, client.Identity(), client.detectionProbability); err != nil {
+			return err
+		}
+		if err := client.SubmitTranscript(index, ceremony); err != nil {
+			return err
+		}
+		if index+1 >= info.TranscriptCount {
+			return nil
+		}
+		// Wait for the participant before us to release the next transcript
+		for {
+			time.Sleep(transcriptPollInterval)
+			if info, err = client.GetCeremony(); err != nil {
+				return err
+			}
+			if info.Transcript != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// inclusionAttempts is the number of seconds we wait for our contribution to appear in the log
+	inclusionAttempts = 30
+	treeHeadPath      = "treehead.json"
+)
+
+var errNotFound = errors.New("not found")
+
+// CheckInclusion waits for our accepted contribution to appear in the log of the coordinator and checks
+// its inclusion proof against a signed tree head. The tree head is saved, s.th. the coordinator can
+// later be audited for showing us a history that was rewritten afterwards.
+func (c *Client) CheckInclusion(receipt *towersofpau.SignedReceipt) (*towersofpau.SignedTreeHead, error) {
+	key := c.coordinatorKey
+	if key == nil {
+		key = common.FromHex(receipt.PublicKey)
+	}
+	for attempt := 0; attempt < inclusionAttempts; attempt++ {
+		if attempt > 0 {
+			// Contributions are logged shortly after the receipt is sent
+			time.Sleep(time.Second)
+		}
+		var head towersofpau.SignedTreeHead
+		if err := c.getJSON("/tree/head", &head); err != nil {
+			return nil, err
+		}
+		if err := head.Verify(key); err != nil {
+			return nil, fmt.Errorf("invalid tree head: %v", err)
+		}
+		var proof towersofpau.LogInclusionProof
+		err := c.getJSON(fmt.Sprintf("/tree/inclusion?hash=%v&size=%v", receipt.Receipt.CeremonyHash, head.TreeHead.Size), &proof)
+		if err == errNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := checkInclusionProof(receipt.Receipt, head.TreeHead, proof); err != nil {
+			return nil, fmt.Errorf("invalid inclusion proof: %v", err)
+		}
+		data, err := json.Marshal(head)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(treeHeadPath, data, 0644); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Our contribution is entry %v of the log of size %v, saved the tree head to %v\n", proof.Index, head.TreeHead.Size, treeHeadPath)
+		return &head, nil
+	}
+	return nil, errors.New("our contribution did not appear in the log")
+}
+
+// checkInclusionProof checks that the proof shows an entry matching our receipt in the tree of head.
+func checkInclusionProof(receipt towersofpau.Receipt, head towersofpau.TreeHead, proof towersofpau.LogInclusionProof) error {
+	entry := proof.Entry
+	if entry.Ceremony != receipt.Ceremony || entry.Slot != receipt.SlotIndex || entry.CeremonyHash != receipt.CeremonyHash ||
+		!reflect.DeepEqual(entry.PotPubkeys, receipt.PotPubkeys) || !reflect.DeepEqual(entry.Transcript, receipt.Transcript) {
+		return errors.New("logged entry does not match our receipt")
+	}
+	return verifyInclusionProof(head, proof)
+}
+
+// verifyInclusionProof checks that the entry of the proof is included in the tree of head.
+func verifyInclusionProof(head towersofpau.TreeHead, proof towersofpau.LogInclusionProof) error {
+	if proof.TreeSize != head.Size || proof.Entry.Index != proof.Index {
+		return errors.New("proof does not match the tree head")
+	}
+	leaf, err := proof.Entry.LeafHash()
+	if err != nil {
+		return err
+	}
+	hashes, err := towersofpau.DecodeHashes(proof.Hashes)
+	if err != nil {
+		return err
+	}
+	root, err := head.Root()
+	if err != nil {
+		return err
+	}
+	if !towersofpau.VerifyInclusion(proof.Index, proof.TreeSize, leaf, hashes, root) {
+		return errors.New("entry is not included in the tree")
+	}
+	return nil
+}
+
+func (c *Client) getJSON(path string, v interface{}) error {
+	resp, err := http.Get(c.url + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		return json.NewDecoder(resp.Body).Decode(v)
+	case 404:
+		return errNotFound
+	}
+	return fmt.Errorf("invalid status code %v", resp.StatusCode)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dknopik/towersofpau"
+)
+
+// maxRequestSize bounds the size of a verification request, it contains two ceremonies
+const maxRequestSize = 256 << 20
+
+func main() {
+	addr := flag.String("addr", ":2017", "address to listen on")
+	token := flag.String("token", "", "bearer token the coordinator has to send, no authentication if empty")
+	flag.Parse()
+	http.HandleFunc("/verify", verify(*token))
+	fmt.Printf("Verifying submissions on %v\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// verify answers POST /verify with whether the submission in the request is valid.
+func verify(token string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			rw.WriteHeader(405)
+			return
+		}
+		bearer := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if token != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			rw.WriteHeader(403)
+			return
+		}
+		var request towersofpau.VerificationRequest
+		if err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxRequestSize)).Decode(&request); err != nil {
+			http.Error(rw, "invalid request", 400)
+			return
+		}
+		start := time.Now()
+		response := towersofpau.VerificationResponse{Valid: true}
+		if err := check(request); err != nil {
+			response = towersofpau.VerificationResponse{Reason: err.Error()}
+		}
+		fmt.Printf("Verified submission in %v, valid: %v %v\n", time.Since(start), response.Valid, response.Reason)
+		resp, err := json.Marshal(response)
+		if err != nil {
+			rw.WriteHeader(500)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write(resp)
+	}
+}
+
+func check(request towersofpau.VerificationRequest) error {
+	prev, err := towersofpau.DeserializeJSONCeremony(request.Previous)
+	if err != nil {
+		return fmt.Errorf("invalid previous ceremony: %v", err)
+	}
+	next, err := towersofpau.DeserializeJSONCeremony(request.Submission)
+	if err != nil {
+		return fmt.Errorf("invalid ceremony: %v", err)
+	}
+	return towersofpau.VerifySubmission(prev, next, request.Identity)
+}
@@ -4,10 +4,174 @@ type RegistrationResponse struct {
 	Start    int64
 	Deadline int64
 	Ticket   string
+	// Identity is the identity the ticket is bound to, empty for anonymous participants
+	Identity string
+}
+
+// CeremonyInfo describes a ceremony run by the coordinator.
+type CeremonyInfo struct {
+	ID string
+	// Path the endpoints of the ceremony are served under
+	Path   string
+	Status Status
 }
 
 type FetchResponse struct {
 	Start    int64
 	Deadline int64
 	Ceremony *JSONCeremony
+	// Transcript is the index of the only transcript in Ceremony if the coordinator is pipelined,
+	// nil if Ceremony contains all transcripts
+	Transcript *int
+	// TranscriptCount is the number of transcripts of the pipelined ceremony
+	TranscriptCount int
+	QueueInfo
+}
+
+// QueueInfo describes the place of a participant in the queue.
+type QueueInfo struct {
+	// Position in the queue, 1 if it is our turn
+	Position int
+	// Ahead is the number of participants before us
+	Ahead int
+	// EstimatedStart is when our turn is expected to start, based on the measured durations of previous slots
+	EstimatedStart int64
+	ServerTime     int64
+}
+
+type Challenge struct {
+	Nonce      string
+	Difficulty int
+	Expires    int64
+}
+
+type RegistrationRequest struct {
+	Nonce    string
+	Solution uint64
+	// Optional Sign-In with Ethereum, binds the ticket to Address
+	SignInNonce string
+	Address     string
+	Signature   string
+	// Benchmark of the participant, used to size its slot
+	Benchmark *ContributionBenchmark
+	// Optional invite code, matched against the allowlist and priority lane of the coordinator
+	Invite string
+	// Optional window the slot has to lie in, unix timestamps
+	WindowStart int64
+	WindowEnd   int64
+}
+
+// TimeWindow is a span of time between two unix timestamps, an End of 0 is open ended.
+type TimeWindow struct {
+	Start int64
+	End   int64
+}
+
+// BookingConflict is returned if the requested window could not be booked.
+type BookingConflict struct {
+	Reason string
+	// Alternatives are the earliest windows a slot could still be booked in
+	Alternatives []TimeWindow
+}
+
+type SignInChallenge struct {
+	Nonce   string
+	Message string
+	Expires int64
+}
+
+// Contribution is the public record of an accepted contribution.
+type Contribution struct {
+	// Index of the contribution in the history
+	Index int
+	// Slot the contribution was submitted in
+	Slot      int
+	Timestamp int64
+	Identity  string
+	// Transcript is the index of the only transcript contributed to in a pipelined ceremony,
+	// nil if the contribution covers all transcripts
+	Transcript *int
+	// PotPubkeys of the contribution, one per transcript contributed to
+	PotPubkeys []string
+	// CeremonyHash is the hash tree root of the ceremony after the contribution
+	CeremonyHash string
+	// Beacon the final contribution was derived from, nil for contributions of participants.
+	// The beacon contribution has no slot, Slot is -1.
+	Beacon *Beacon
+}
+
+type HistoryPage struct {
+	Page          int
+	PageSize      int
+	Total         int
+	Contributions []Contribution
+}
+
+type Status struct {
+	Phase         string
+	QueueLength   int
+	ActiveSlot    int
+	Registrations int
+	Contributions int
+	ServerTime    int64
+}
+
+// PositionEvent informs a participant about its place in the queue.
+type PositionEvent struct {
+	Start    int64
+	Deadline int64
+	QueueInfo
+}
+
+type VerificationEvent struct {
+	Slot     int
+	Accepted bool
+	Reason   string
+}
+
+// Policy is the participant policy of the coordinator, as managed through the admin API.
+type Policy struct {
+	Closed    bool
+	Allowlist []string
+	Priority  []string
+	Bans      []string
+	// Strikes counts the missed slots and invalid submissions per identity or IP
+	Strikes map[string]int
+}
+
+// VerificationRequest asks a remote verifier to check a submission on top of the previous ceremony.
+type VerificationRequest struct {
+	Previous   JSONCeremony
+	Submission JSONCeremony
+	// Identity the submission has to be signed by, empty if no signatures are required
+	Identity string
+}
+
+type VerificationResponse struct {
+	Valid  bool
+	Reason string
+}
+
+// LogInclusionProof proves that Entry is the entry with Index in the log tree of TreeSize entries.
+type LogInclusionProof struct {
+	TreeSize int
+	Index    int
+	Entry    LogEntry
+	// Hashes is the audit path of the leaf of the entry
+	Hashes []string
+}
+
+// LogConsistencyProof proves that the log tree of size First is a prefix of the tree of size Second.
+type LogConsistencyProof struct {
+	First  int
+	Second int
+	Hashes []string
+}
+
+// Alert is raised by a mirror that caught the coordinator misbehaving.
+type Alert struct {
+	Time   int64
+	Reason string
+	// Checkpoint is the conflicting checkpoint of another observer, nil if the mirror detected the problem itself
+	Checkpoint *SignedCheckpoint
 }
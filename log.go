@@ -0,0 +1,96 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// logDomain separates log entry signatures from other signatures of the coordinator key
+const logDomain = "towersofpau log\n"
+
+// LogEntry is an entry of the append-only contribution log. Every entry commits to the previous one,
+// s.th. entries can not be removed, reordered or altered without breaking the chain.
+type LogEntry struct {
+	Ceremony string
+	// Index of the entry in the log, equal to the index of the contribution in the history
+	Index int
+	// PrevHash is the hash of the previous entry, empty for the first entry
+	PrevHash   string
+	Slot       int
+	Transcript *int
+	Identity   string
+	PotPubkeys []string
+	// CeremonyHash is the hash tree root of the ceremony after the contribution
+	CeremonyHash string
+	Timestamp    int64
+	// Beacon the final contribution was derived from, nil for contributions of participants
+	Beacon *Beacon
+}
+
+type SignedLogEntry struct {
+	Entry     LogEntry
+	PublicKey string
+	Signature string
+}
+
+// Hash returns the hash the next entry links to.
+func (e *LogEntry) Hash() (string, error) {
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(msg)
+	return "0x" + hex.EncodeToString(hash[:]), nil
+}
+
+// SignLogEntry signs the entry with the long-term key of the coordinator.
+func SignLogEntry(entry LogEntry, key ed25519.PrivateKey) (*SignedLogEntry, error) {
+	pub, sig, err := signJSON(logDomain, entry, key)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedLogEntry{
+		Entry:     entry,
+		PublicKey: pub,
+		Signature: sig,
+	}, nil
+}
+
+// Verify checks the signature of the entry, if key is nil the public key contained in the entry is used.
+func (e *SignedLogEntry) Verify(key ed25519.PublicKey) error {
+	return verifyJSON(logDomain, e.Entry, e.PublicKey, e.Signature, key, "entry")
+}
+
+// VerifyLog checks that the log is complete, in order, unaltered and signed by a single key.
+// If key is nil the key of the first entry is used. It does not check the ceremonies themselves.
+func VerifyLog(log []SignedLogEntry, key ed25519.PublicKey) error {
+	var prevHash string
+	for i := range log {
+		entry := &log[i]
+		if key == nil {
+			pub, err := hex.DecodeString(strings.TrimPrefix(entry.PublicKey, "0x"))
+			if err != nil || len(pub) != ed25519.PublicKeySize {
+				return fmt.Errorf("entry %v: invalid public key", i)
+			}
+			key = pub
+		}
+		if err := entry.Verify(key); err != nil {
+			return fmt.Errorf("entry %v: %v", i, err)
+		}
+		if entry.Entry.Index != i {
+			return fmt.Errorf("entry %v: has index %v, entries are missing or reordered", i, entry.Entry.Index)
+		}
+		if entry.Entry.PrevHash != prevHash {
+			return fmt.Errorf("entry %v: does not link to the previous entry", i)
+		}
+		var err error
+		if prevHash, err = entry.Entry.Hash(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log []SignedLogEntry
+	var prevHash string
+	for i := 0; i < 4; i++ {
+		entry := LogEntry{
+			Index:        i,
+			PrevHash:     prevHash,
+			Slot:         i,
+			PotPubkeys:   []string{"0xabcd"},
+			CeremonyHash: "0x1234",
+			Timestamp:    123123123 + int64(i),
+		}
+		signed, err := SignLogEntry(entry, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log = append(log, *signed)
+		if prevHash, err = entry.Hash(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := VerifyLog(log, pub); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLog(log, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := append(append([]SignedLogEntry{}, log[:1]...), log[2:]...)
+	if err := VerifyLog(missing, pub); err == nil {
+		t.Fatal("log with missing entry accepted")
+	}
+	reordered := []SignedLogEntry{log[0], log[2], log[1], log[3]}
+	if err := VerifyLog(reordered, pub); err == nil {
+		t.Fatal("reordered log accepted")
+	}
+	altered := append([]SignedLogEntry{}, log...)
+	altered[1].Entry.Identity = "eth|0x0123"
+	if err := VerifyLog(altered, pub); err == nil {
+		t.Fatal("altered log accepted")
+	}
+	otherPub, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyLog(log, otherPub); err == nil {
+		t.Fatal("log accepted for other key")
+	}
+	// A resigned entry breaks the link of the next entry
+	forged := append([]SignedLogEntry{}, log...)
+	entry := log[1].Entry
+	entry.CeremonyHash = "0x5678"
+	resigned, err := SignLogEntry(entry, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged[1] = *resigned
+	if err := VerifyLog(forged, pub); err == nil {
+		t.Fatal("log with replaced entry accepted")
+	}
+	mixed := append([]SignedLogEntry{}, log...)
+	mixedEntry, err := SignLogEntry(log[3].Entry, otherPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed[3] = *mixedEntry
+	if err := VerifyLog(mixed, nil); err == nil {
+		t.Fatal("log signed by several keys accepted")
+	}
+}
@@ -0,0 +1,220 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// treeHeadDomain separates tree head signatures from other signatures of the coordinator key
+const treeHeadDomain = "towersofpau tree head\n"
+
+// The Merkle tree over the log follows RFC 6962: leaves are hashed with a 0x00 prefix,
+// inner nodes with a 0x01 prefix, and the left subtree is always the largest power of two.
+
+func MerkleLeafHash(data []byte) [32]byte {
+	return sha256.Sum256(append([]byte{0}, data...))
+}
+
+func merkleNodeHash(left, right [32]byte) [32]byte {
+	return sha256.Sum256(append(append([]byte{1}, left[:]...), right[:]...))
+}
+
+// LeafHash returns the hash of the entry as a leaf of the Merkle tree.
+func (e *LogEntry) LeafHash() ([32]byte, error) {
+	msg, err := json.Marshal(e)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return MerkleLeafHash(msg), nil
+}
+
+// splitPoint returns the largest power of two smaller than n, n has to be at least 2.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// MerkleRoot returns the root of the tree over the leaf hashes.
+func MerkleRoot(leaves [][32]byte) [32]byte {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return merkleNodeHash(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+// InclusionProof returns the audit path of the leaf with the index in the tree over leaves.
+func InclusionProof(leaves [][32]byte, index int) [][32]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if index < k {
+		return append(InclusionProof(leaves[:k], index), MerkleRoot(leaves[k:]))
+	}
+	return append(InclusionProof(leaves[k:], index-k), MerkleRoot(leaves[:k]))
+}
+
+// ConsistencyProof returns the proof that the tree over the first size leaves is a prefix of the tree over leaves.
+func ConsistencyProof(leaves [][32]byte, size int) [][32]byte {
+	if size <= 0 || size >= len(leaves) {
+		return nil
+	}
+	return subproof(leaves, size, true)
+}
+
+func subproof(leaves [][32]byte, size int, complete bool) [][32]byte {
+	if size == len(leaves) {
+		if complete {
+			return nil
+		}
+		return [][32]byte{MerkleRoot(leaves)}
+	}
+	k := splitPoint(len(leaves))
+	if size <= k {
+		return append(subproof(leaves[:k], size, complete), MerkleRoot(leaves[k:]))
+	}
+	return append(subproof(leaves[k:], size-k, false), MerkleRoot(leaves[:k]))
+}
+
+// VerifyInclusion checks that leaf is the leaf with the index in the tree of size with root.
+func VerifyInclusion(index, size int, leaf [32]byte, proof [][32]byte, root [32]byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && r == root
+}
+
+// VerifyConsistency checks that the tree of size first with firstRoot is a prefix of the tree of
+// size second with secondRoot.
+func VerifyConsistency(first, second int, firstRoot, secondRoot [32]byte, proof [][32]byte) bool {
+	switch {
+	case first < 0 || first > second:
+		return false
+	case first == second:
+		return len(proof) == 0 && firstRoot == secondRoot
+	case first == 0:
+		// Every tree is consistent with the empty tree
+		return len(proof) == 0
+	}
+	if first&(first-1) == 0 {
+		// The smaller tree is a complete subtree, its root is not part of the proof
+		proof = append([][32]byte{firstRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return false
+	}
+	fn, sn := first-1, second-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = merkleNodeHash(c, fr)
+			sr = merkleNodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkleNodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && fr == firstRoot && sr == secondRoot
+}
+
+func EncodeHashes(hashes [][32]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = "0x" + hex.EncodeToString(hash[:])
+	}
+	return encoded
+}
+
+func DecodeHashes(encoded []string) ([][32]byte, error) {
+	hashes := make([][32]byte, len(encoded))
+	for i, e := range encoded {
+		b, err := hex.DecodeString(strings.TrimPrefix(e, "0x"))
+		if err != nil || len(b) != 32 {
+			return nil, errors.New("invalid hash")
+		}
+		copy(hashes[i][:], b)
+	}
+	return hashes, nil
+}
+
+// TreeHead commits to the first Size entries of the log of a ceremony.
+type TreeHead struct {
+	Ceremony  string
+	Size      int
+	RootHash  string
+	Timestamp int64
+}
+
+// Root returns the decoded root hash.
+func (h *TreeHead) Root() ([32]byte, error) {
+	hashes, err := DecodeHashes([]string{h.RootHash})
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return hashes[0], nil
+}
+
+type SignedTreeHead struct {
+	TreeHead  TreeHead
+	PublicKey string
+	Signature string
+}
+
+// SignTreeHead signs the tree head with the long-term key of the coordinator.
+func SignTreeHead(head TreeHead, key ed25519.PrivateKey) (*SignedTreeHead, error) {
+	pub, sig, err := signJSON(treeHeadDomain, head, key)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedTreeHead{
+		TreeHead:  head,
+		PublicKey: pub,
+		Signature: sig,
+	}, nil
+}
+
+// Verify checks the signature of the tree head, if key is nil the public key contained in the tree head is used.
+func (h *SignedTreeHead) Verify(key ed25519.PublicKey) error {
+	return verifyJSON(treeHeadDomain, h.TreeHead, h.PublicKey, h.Signature, key, "tree head")
+}
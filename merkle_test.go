@@ -0,0 +1,91 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func testLeaves(n int) [][32]byte {
+	leaves := make([][32]byte, n)
+	for i := range leaves {
+		leaves[i] = MerkleLeafHash([]byte{byte(i)})
+	}
+	return leaves
+}
+
+func TestMerkleRoot(t *testing.T) {
+	empty := MerkleRoot(nil)
+	if hex.EncodeToString(empty[:]) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal("invalid root of the empty tree")
+	}
+	leaves := testLeaves(3)
+	expected := merkleNodeHash(merkleNodeHash(leaves[0], leaves[1]), leaves[2])
+	if MerkleRoot(leaves) != expected {
+		t.Fatal("invalid root")
+	}
+	if leaves[0] != sha256.Sum256([]byte{0, 0}) {
+		t.Fatal("invalid leaf hash")
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	for size := 1; size <= 20; size++ {
+		leaves := testLeaves(size)
+		root := MerkleRoot(leaves)
+		for i := 0; i < size; i++ {
+			proof := InclusionProof(leaves, i)
+			if !VerifyInclusion(i, size, leaves[i], proof, root) {
+				t.Fatalf("valid inclusion proof of %v in %v rejected", i, size)
+			}
+			if VerifyInclusion(i, size, MerkleLeafHash([]byte("other")), proof, root) {
+				t.Fatalf("inclusion proof of other leaf at %v in %v accepted", i, size)
+			}
+			if size > 1 && VerifyInclusion((i+1)%size, size, leaves[i], proof, root) {
+				t.Fatalf("inclusion proof of %v in %v accepted at other index", i, size)
+			}
+		}
+	}
+}
+
+func TestConsistencyProof(t *testing.T) {
+	for second := 1; second <= 20; second++ {
+		leaves := testLeaves(second)
+		secondRoot := MerkleRoot(leaves)
+		for first := 1; first <= second; first++ {
+			firstRoot := MerkleRoot(leaves[:first])
+			proof := ConsistencyProof(leaves, first)
+			if !VerifyConsistency(first, second, firstRoot, secondRoot, proof) {
+				t.Fatalf("valid consistency proof from %v to %v rejected", first, second)
+			}
+			// A forked history is not consistent
+			forked := append(testLeaves(first-1), MerkleLeafHash([]byte("fork")))
+			if first < second && VerifyConsistency(first, second, MerkleRoot(forked), secondRoot, proof) {
+				t.Fatalf("consistency proof from forked %v to %v accepted", first, second)
+			}
+		}
+	}
+}
+
+func TestTreeHead(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := MerkleRoot(testLeaves(5))
+	head, err := SignTreeHead(TreeHead{Size: 5, RootHash: EncodeHashes([][32]byte{root})[0], Timestamp: 123123123}, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := head.Verify(pub); err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := head.TreeHead.Root(); err != nil || decoded != root {
+		t.Fatal("invalid root hash encoding")
+	}
+	head.TreeHead.Size = 6
+	if err := head.Verify(pub); err == nil {
+		t.Fatal("modified tree head accepted")
+	}
+}
@@ -0,0 +1,50 @@
+package towersofpau
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+// PubkeyIndex remembers the pot pubkeys of all accepted contributions, s.th. a secret can not be used twice.
+// Unlike PubkeyUniquenessCheck it tolerates the repeated pubkeys of the initial ceremony.
+type PubkeyIndex struct {
+	mutex sync.Mutex
+	used  map[string]bool
+}
+
+// NewPubkeyIndex creates an index containing every pot pubkey of the ceremony.
+func NewPubkeyIndex(ceremony *Ceremony) *PubkeyIndex {
+	used := make(map[string]bool)
+	for _, transcript := range ceremony.Transcripts {
+		for _, pk := range transcript.Witness.PotPubkeys {
+			used["0x"+hex.EncodeToString(pk.Compress())] = true
+		}
+	}
+	return &PubkeyIndex{used: used}
+}
+
+// Add adds the hex encoded pot pubkeys of a contribution to the index. If any of them was used before,
+// or twice in the contribution, none of them is added.
+func (i *PubkeyIndex) Add(pubkeys []string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	seen := make(map[string]bool, len(pubkeys))
+	for _, pk := range pubkeys {
+		if i.used[pk] || seen[pk] {
+			return fmt.Errorf("pot pubkey %v was used before", pk)
+		}
+		seen[pk] = true
+	}
+	for _, pk := range pubkeys {
+		i.used[pk] = true
+	}
+	return nil
+}
+
+// Contains returns whether the hex encoded pot pubkey was used.
+func (i *PubkeyIndex) Contains(pubkey string) bool {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.used[pubkey]
+}
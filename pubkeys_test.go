@@ -0,0 +1,39 @@
+package towersofpau
+
+import "testing"
+
+func TestPubkeyIndex(t *testing.T) {
+	ceremony := newTestCeremony()
+	index := NewPubkeyIndex(ceremony)
+	// The initial pubkeys of both transcripts are the generator
+	if !index.Contains(ceremony.LatestPotPubkeys()[0]) {
+		t.Fatal("initial pubkey missing")
+	}
+
+	next := ceremony.Copy()
+	if err := UpdateTranscript(next, ""); err != nil {
+		t.Fatal(err)
+	}
+	pubkeys := next.LatestPotPubkeys()
+	if err := index.Add(pubkeys); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Add(pubkeys[1:]); err == nil {
+		t.Fatal("replayed pubkey accepted")
+	}
+
+	other := next.Copy()
+	if err := UpdateTranscript(other, ""); err != nil {
+		t.Fatal(err)
+	}
+	repeated := []string{other.LatestPotPubkeys()[0], other.LatestPotPubkeys()[0]}
+	if err := index.Add(repeated); err == nil {
+		t.Fatal("pubkey repeated in a contribution accepted")
+	}
+	if index.Contains(repeated[0]) {
+		t.Fatal("pubkey of a rejected contribution added")
+	}
+	if err := index.Add(other.LatestPotPubkeys()); err != nil {
+		t.Fatal(err)
+	}
+}
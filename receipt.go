@@ -0,0 +1,47 @@
+package towersofpau
+
+import "crypto/ed25519"
+
+// receiptDomain separates receipt signatures from other signatures of the coordinator key
+const receiptDomain = "towersofpau receipt\n"
+
+// Receipt is the statement of the coordinator about the outcome of a submission.
+type Receipt struct {
+	// Ceremony is the ID of the ceremony the submission was made to
+	Ceremony  string
+	SlotIndex int
+	// Transcript is the index of the submitted transcript if the coordinator is pipelined
+	Transcript *int
+	// Identity of the participant, the ticket for anonymous participants
+	Identity     string
+	PotPubkeys   []string
+	CeremonyHash string
+	Timestamp    int64
+	Accepted     bool
+	// Reason why the submission was rejected
+	Reason string
+}
+
+type SignedReceipt struct {
+	Receipt   Receipt
+	PublicKey string
+	Signature string
+}
+
+// SignReceipt signs the receipt with the long-term key of the coordinator.
+func SignReceipt(receipt Receipt, key ed25519.PrivateKey) (*SignedReceipt, error) {
+	pub, sig, err := signJSON(receiptDomain, receipt, key)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedReceipt{
+		Receipt:   receipt,
+		PublicKey: pub,
+		Signature: sig,
+	}, nil
+}
+
+// Verify checks the signature of the receipt, if key is nil the public key contained in the receipt is used.
+func (r *SignedReceipt) Verify(key ed25519.PublicKey) error {
+	return verifyJSON(receiptDomain, r.Receipt, r.PublicKey, r.Signature, key, "receipt")
+}
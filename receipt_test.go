@@ -0,0 +1,41 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestReceipt(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt, err := SignReceipt(Receipt{
+		SlotIndex:  3,
+		Identity:   "eth|0x0123",
+		PotPubkeys: []string{"0xabcd"},
+		Timestamp:  123123123,
+		Accepted:   true,
+	}, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receipt.Verify(pub); err != nil {
+		t.Fatal(err)
+	}
+	if err := receipt.Verify(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receipt.Verify(otherPub); err == nil {
+		t.Fatal("receipt accepted for other key")
+	}
+	receipt.Receipt.Accepted = false
+	if err := receipt.Verify(pub); err == nil {
+		t.Fatal("modified receipt accepted")
+	}
+}
@@ -0,0 +1,164 @@
+package towersofpau
+
+import (
+	"crypto/rand"
+	"errors"
+	"math"
+
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// PairingReport describes what VerifyPairingSampled checked.
+type PairingReport struct {
+	// Probability is the requested probability of detecting an invalid relation
+	Probability float64
+	// Relations is the number of pairing relations of each transcript, all of them are part of the check
+	Relations []int
+	// Bits is the number of random bits of every coefficient of the linear combinations,
+	// 0 if every relation was checked with its own pairing
+	Bits int
+	// Detection is the probability with which any invalid relation was detected, 1 - 2^-Bits, at least Probability
+	Detection float64
+	// Valid is set if the check passed
+	Valid bool
+}
+
+// VerifyPairingSampled checks the pairing relations VerifyPairing checks in random linear combinations. Of every
+// transcript the relations between adjacent G1 powers, adjacent G2 powers and running products are each combined
+// with random coefficients into a single pairing equation, which costs a multi-scalar multiplication instead of a
+// pairing per relation. If a relation does not hold, the combined equation holds for at most one value of its
+// coefficient, so with coefficients drawn from 2^bits values it is missed with probability at most 2^-bits,
+// regardless of the number of relations and how many of them are invalid. bits is the smallest number s.th. an
+// invalid relation is detected with at least the given probability, a probability of 1 checks every relation with
+// its own pairing instead. The points have to be in their prime order subgroups, see SubgroupChecksParticipant.
+// The coefficients are drawn with crypto/rand, s.th. nobody can predict them.
+func VerifyPairingSampled(ceremony *Ceremony, probability float64) (*PairingReport, error) {
+	if !(probability > 0 && probability <= 1) {
+		return nil, errors.New("detection probability has to be in (0, 1]")
+	}
+	report := &PairingReport{
+		Probability: probability,
+		Detection:   1,
+		Valid:       true,
+	}
+	if probability < 1 {
+		report.Bits = coefficientBits(probability)
+		report.Detection = 1 - math.Pow(2, -float64(report.Bits))
+	}
+	for _, t := range ceremony.Transcripts {
+		if len(t.Witness.PotPubkeys) != len(t.Witness.RunningProducts) ||
+			len(t.PowersOfTau.G1Powers) < 2 || len(t.PowersOfTau.G2Powers) < 2 {
+			return nil, errors.New("invalid transcript")
+		}
+		report.Relations = append(report.Relations, pairingRelations(t))
+		valid := true
+		if report.Bits == 0 {
+			valid = verifyPairing(t)
+		} else {
+			var err error
+			if valid, err = checkCombinedRelations(t, report.Bits); err != nil {
+				return nil, err
+			}
+		}
+		if !valid {
+			report.Valid = false
+		}
+	}
+	return report, nil
+}
+
+// coefficientBits returns the smallest number of bits s.th. 1 - 2^-bits is at least probability.
+func coefficientBits(probability float64) int {
+	bits := int(math.Ceil(-math.Log2(1 - probability)))
+	if bits < 1 {
+		bits = 1
+	}
+	return bits
+}
+
+// checkCombinedRelations checks the random linear combinations of the pairing relations of the transcript.
+func checkCombinedRelations(t *Transcript, bits int) (bool, error) {
+	var (
+		g1Powers = blst.P1sToAffine(t.PowersOfTau.G1Powers)
+		g2Powers = blst.P2sToAffine(t.PowersOfTau.G2Powers)
+		products = t.Witness.RunningProducts
+		p2_g     = blst.P2Generator().ToAffine()
+		// The coefficients are odd, s.th. none of them is zero
+		nbits = bits + 1
+		size  = (nbits + 7) / 8
+	)
+
+	// e(sum r_i * g1_i, g2_1) = e(sum r_i * g1_{i+1}, g2_0)
+	r, err := randomCoefficients(len(g1Powers)-1, bits)
+	if err != nil {
+		return false, err
+	}
+	lower := combineG1(g1Powers[:len(g1Powers)-1], r, nbits)
+	upper := combineG1(g1Powers[1:], r, nbits)
+	if !blst.Fp12FinalVerify(blst.Fp12MillerLoop(&g2Powers[1], lower), blst.Fp12MillerLoop(&g2Powers[0], upper)) {
+		return false, nil
+	}
+
+	// e(g1_1, sum r_i * g2_i) = e(g1_0, sum r_i * g2_{i+1})
+	if r, err = randomCoefficients(len(g2Powers)-1, bits); err != nil {
+		return false, err
+	}
+	lower2 := combineG2(g2Powers[:len(g2Powers)-1], r, nbits)
+	upper2 := combineG2(g2Powers[1:], r, nbits)
+	if !blst.Fp12FinalVerify(blst.Fp12MillerLoop(lower2, &g1Powers[1]), blst.Fp12MillerLoop(upper2, &g1Powers[0])) {
+		return false, nil
+	}
+
+	// prod e(r_i * product_i, pubkey_{i+1}) = e(sum r_i * product_{i+1}, g2)
+	if len(products) < 2 {
+		return true, nil
+	}
+	if r, err = randomCoefficients(len(products)-1, bits); err != nil {
+		return false, err
+	}
+	left := blst.Fp12One()
+	for i := 0; i < len(products)-1; i++ {
+		scaled := products[i].Mult(r[i*size:(i+1)*size], nbits).ToAffine()
+		left.MulAssign(blst.Fp12MillerLoop(&t.Witness.PotPubkeys[i+1], scaled))
+	}
+	right := combineG1(blst.P1sToAffine(products[1:]), r, nbits)
+	return blst.Fp12FinalVerify(&left, blst.Fp12MillerLoop(p2_g, right)), nil
+}
+
+// combineG1 returns the sum of the points multiplied with the coefficients.
+func combineG1(points blst.P1Affines, coefficients []byte, nbits int) *blst.P1Affine {
+	if len(points) == 1 {
+		// The multi-scalar multiplication of blst returns wrong results for a single point
+		var p blst.P1
+		p.FromAffine(&points[0])
+		return p.Mult(coefficients, nbits).ToAffine()
+	}
+	return points.Mult(coefficients, nbits).ToAffine()
+}
+
+// combineG2 returns the sum of the points multiplied with the coefficients.
+func combineG2(points blst.P2Affines, coefficients []byte, nbits int) *blst.P2Affine {
+	if len(points) == 1 {
+		// The multi-scalar multiplication of blst returns wrong results for a single point
+		var p blst.P2
+		p.FromAffine(&points[0])
+		return p.Mult(coefficients, nbits).ToAffine()
+	}
+	return points.Mult(coefficients, nbits).ToAffine()
+}
+
+// randomCoefficients returns count random odd coefficients with bits random bits, encoded little endian
+// in bits+1 bits each, as expected by the multi-scalar multiplications of blst.
+func randomCoefficients(count, bits int) ([]byte, error) {
+	size := (bits + 1 + 7) / 8
+	coefficients := make([]byte, count*size)
+	if _, err := rand.Read(coefficients); err != nil {
+		return nil, err
+	}
+	mask := byte(0xff >> (8*size - bits - 1))
+	for i := 0; i < count; i++ {
+		coefficients[i*size] |= 1
+		coefficients[(i+1)*size-1] &= mask
+	}
+	return coefficients, nil
+}
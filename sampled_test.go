@@ -0,0 +1,83 @@
+package towersofpau
+
+import (
+	"reflect"
+	"testing"
+
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+func TestVerifyPairingSampled(t *testing.T) {
+	ceremony := newTestCeremony()
+	if err := UpdateTranscript(ceremony, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		probability float64
+		bits        int
+	}{{0.5, 1}, {0.99, 7}, {0.999999, 20}, {1, 0}} {
+		report, err := VerifyPairingSampled(ceremony, test.probability)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 15+3+1 and 7+1+1 relations
+		if !report.Valid || !reflect.DeepEqual(report.Relations, []int{19, 9}) || report.Bits != test.bits {
+			t.Fatalf("unexpected report: %+v", report)
+		}
+		if report.Probability != test.probability || report.Detection < test.probability {
+			t.Fatalf("wrong detection probability %v for %v", report.Detection, test.probability)
+		}
+	}
+
+	for _, probability := range []float64{0, -1, 1.5} {
+		if _, err := VerifyPairingSampled(ceremony, probability); err == nil {
+			t.Fatalf("probability %v accepted", probability)
+		}
+	}
+}
+
+func TestVerifyPairingSampledDetection(t *testing.T) {
+	ceremony := newTestCeremony()
+	if err := UpdateTranscript(ceremony, ""); err != nil {
+		t.Fatal(err)
+	}
+	var corrupted []*Ceremony
+	// The last G1 power is part of a single relation, a power in the middle of two
+	for _, corrupt := range []func(c *Ceremony){
+		func(c *Ceremony) { c.Transcripts[0].PowersOfTau.G1Powers[15] = blst.P1Generator() },
+		func(c *Ceremony) { c.Transcripts[1].PowersOfTau.G1Powers[3] = blst.P1Generator() },
+		func(c *Ceremony) { c.Transcripts[0].PowersOfTau.G2Powers[2] = blst.P2Generator() },
+		func(c *Ceremony) { c.Transcripts[1].Witness.RunningProducts[1] = blst.P1Generator() },
+	} {
+		broken := ceremony.Copy()
+		corrupt(broken)
+		if VerifyPairing(broken) {
+			t.Fatal("broken ceremony passed the full check")
+		}
+		corrupted = append(corrupted, broken)
+	}
+
+	for _, test := range []struct {
+		probability float64
+		runs        int
+		// minimum number of detections, far enough below runs*Detection s.th. the test does not flake
+		min int
+	}{{1, 2, 2}, {0.5, 60, 20}, {0.99, 60, 55}, {0.999999, 20, 20}} {
+		for i, broken := range corrupted {
+			detected := 0
+			var report *PairingReport
+			for run := 0; run < test.runs; run++ {
+				var err error
+				if report, err = VerifyPairingSampled(broken, test.probability); err != nil {
+					t.Fatal(err)
+				}
+				if !report.Valid {
+					detected++
+				}
+			}
+			if detected < test.min {
+				t.Fatalf("corruption %v detected %v of %v times with probability %v", i, detected, test.runs, report.Detection)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package towersofpau
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// signJSON signs domain || json(v) and returns the hex encoded public key and signature.
+func signJSON(domain string, v interface{}, key ed25519.PrivateKey) (string, string, error) {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return "", "", err
+	}
+	sig := ed25519.Sign(key, append([]byte(domain), msg...))
+	return "0x" + hex.EncodeToString(key.Public().(ed25519.PublicKey)), "0x" + hex.EncodeToString(sig), nil
+}
+
+// verifyJSON checks a signature created by signJSON, if key is nil publicKey is used.
+// what names the signed object in errors.
+func verifyJSON(domain string, v interface{}, publicKey, signature string, key ed25519.PublicKey, what string) error {
+	signer, err := hex.DecodeString(strings.TrimPrefix(publicKey, "0x"))
+	if err != nil || len(signer) != ed25519.PublicKeySize {
+		return errors.New("invalid public key")
+	}
+	if key != nil && !key.Equal(ed25519.PublicKey(signer)) {
+		return fmt.Errorf("%v signed by unexpected key", what)
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return errors.New("invalid signature encoding")
+	}
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(signer, append([]byte(domain), msg...), sig) {
+		return fmt.Errorf("invalid %v signature", what)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package towersofpau
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SIWEMessage creates a Sign-In with Ethereum (EIP-4361) message that binds
+// address to a registration with the coordinator at domain.
+func SIWEMessage(domain string, address common.Address, nonce string, issuedAt, expires time.Time) string {
+	return fmt.Sprintf(`%v wants you to sign in with your Ethereum account:
+%v
+
+Register for the towers of pau KZG ceremony.
+
+URI: https://%v
+Version: 1
+Chain ID: 1
+Nonce: %v
+Issued At: %v
+Expiration Time: %v`,
+		domain, address.Hex(), domain, nonce,
+		issuedAt.UTC().Format(time.RFC3339), expires.UTC().Format(time.RFC3339))
+}
+
+// EthereumIdentity returns the identity string of an Ethereum address.
+func EthereumIdentity(address common.Address) string {
+	return "eth|" + strings.ToLower(address.Hex())
+}
+
+// SignSIWE signs message like personal_sign does.
+func SignSIWE(message string, key *ecdsa.PrivateKey) ([]byte, error) {
+	sig, err := crypto.Sign(textHash(message), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
+
+// VerifySIWE checks that signature is a personal_sign signature of message by address.
+func VerifySIWE(message string, signature []byte, address common.Address) error {
+	if len(signature) != crypto.SignatureLength {
+		return errors.New("invalid signature length")
+	}
+	sig := common.CopyBytes(signature)
+	// Wallets return the recovery id as 27 or 28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pubkey, err := crypto.SigToPub(textHash(message), sig)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(*pubkey) != address {
+		return errors.New("signature does not match address")
+	}
+	return nil
+}
+
+// textHash is the hash signed by personal_sign.
+func textHash(message string) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))
+	return crypto.Keccak256([]byte(prefix), []byte(message))
+}
@@ -0,0 +1,34 @@
+package towersofpau
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSIWE(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	message := SIWEMessage("example.com", address, "abcdef", time.Now(), time.Now().Add(time.Minute))
+	sig, err := SignSIWE(message, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySIWE(message, sig, address); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySIWE(message+"x", sig, address); err == nil {
+		t.Fatal("signature over different message accepted")
+	}
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySIWE(message, sig, crypto.PubkeyToAddress(other.PublicKey)); err == nil {
+		t.Fatal("signature accepted for different address")
+	}
+}
@@ -0,0 +1,475 @@
+package towersofpau
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// The SSZ schema of a ceremony, points are compressed:
+//
+//	PowersOfTau { G1Powers: List[Bytes48, MaxG1Powers], G2Powers: List[Bytes96, MaxG2Powers] }
+//	Witness     { RunningProducts: List[Bytes48, MaxContributions], PotPubkeys: List[Bytes96, MaxContributions],
+//	              BlsSignatures: List[Bytes48, MaxContributions] }
+//	Transcript  { NumG1Powers: uint64, NumG2Powers: uint64, PowersOfTau: PowersOfTau, Witness: Witness }
+//	Ceremony    { Transcripts: List[Transcript, MaxTranscripts] }
+//
+// Missing BLS signatures are encoded as the point at infinity, there is one signature per pot pubkey.
+const (
+	MaxG1Powers      = 1 << 15
+	MaxG2Powers      = 1 << 7
+	MaxContributions = 1 << 20
+	MaxTranscripts   = 1 << 4
+
+	g1Size     = 48
+	g2Size     = 96
+	offsetSize = 4
+)
+
+// infinitySignature is the compressed G1 point at infinity
+var infinitySignature = append([]byte{0xc0}, make([]byte, g1Size-1)...)
+
+func (p *PowersOfTau) MarshalSSZ() ([]byte, error) {
+	g1, err := marshalP1s(p.G1Powers, MaxG1Powers)
+	if err != nil {
+		return nil, err
+	}
+	g2, err := marshalP2s(p.G2Powers, MaxG2Powers)
+	if err != nil {
+		return nil, err
+	}
+	return marshalContainer(nil, g1, g2), nil
+}
+
+func (p *PowersOfTau) UnmarshalSSZ(b []byte) error {
+	fields, err := unmarshalContainer(b, 0, 2)
+	if err != nil {
+		return err
+	}
+	if p.G1Powers, err = unmarshalP1s(fields[0], MaxG1Powers); err != nil {
+		return err
+	}
+	p.G2Powers, err = unmarshalP2s(fields[1], MaxG2Powers)
+	return err
+}
+
+func (p *PowersOfTau) HashTreeRoot() ([32]byte, error) {
+	if len(p.G1Powers) > MaxG1Powers || len(p.G2Powers) > MaxG2Powers {
+		return [32]byte{}, errors.New("too many powers")
+	}
+	g1 := make([][]byte, len(p.G1Powers))
+	for i, point := range p.G1Powers {
+		g1[i] = point.Compress()
+	}
+	g2 := make([][]byte, len(p.G2Powers))
+	for i, point := range p.G2Powers {
+		g2[i] = point.Compress()
+	}
+	return merkleize([][32]byte{bytesListRoot(g1, MaxG1Powers), bytesListRoot(g2, MaxG2Powers)}, 2), nil
+}
+
+func (w *Witness) MarshalSSZ() ([]byte, error) {
+	products, err := marshalP1s(w.RunningProducts, MaxContributions)
+	if err != nil {
+		return nil, err
+	}
+	pubkeys := w.compressedPubkeys()
+	if len(pubkeys) > MaxContributions {
+		return nil, errors.New("too many pot pubkeys")
+	}
+	return marshalContainer(nil, products, concat(pubkeys), concat(w.compressedSignatures())), nil
+}
+
+func (w *Witness) UnmarshalSSZ(b []byte) error {
+	fields, err := unmarshalContainer(b, 0, 3)
+	if err != nil {
+		return err
+	}
+	if w.RunningProducts, err = unmarshalP1s(fields[0], MaxContributions); err != nil {
+		return err
+	}
+	pubkeys, err := split(fields[1], g2Size, MaxContributions)
+	if err != nil {
+		return err
+	}
+	w.PotPubkeys = make(blst.P2Affines, len(pubkeys))
+	for i, point := range new(blst.P2Affine).BatchUncompress(pubkeys) {
+		if point == nil {
+			return errors.New("invalid pot pubkey")
+		}
+		w.PotPubkeys[i] = *point
+	}
+	signatures, err := split(fields[2], g1Size, MaxContributions)
+	if err != nil {
+		return err
+	}
+	if len(signatures) != len(pubkeys) {
+		return errors.New("number of bls signatures does not match the pot pubkeys")
+	}
+	w.BlsSignatures = make([]*blst.P1Affine, len(signatures))
+	for i, sig := range signatures {
+		if string(sig) == string(infinitySignature) {
+			continue
+		}
+		if w.BlsSignatures[i] = new(blst.P1Affine).Uncompress(sig); w.BlsSignatures[i] == nil {
+			return errors.New("invalid bls signature")
+		}
+	}
+	return nil
+}
+
+func (w *Witness) HashTreeRoot() ([32]byte, error) {
+	if len(w.RunningProducts) > MaxContributions || len(w.PotPubkeys) > MaxContributions {
+		return [32]byte{}, errors.New("too many contributions")
+	}
+	products := make([][]byte, len(w.RunningProducts))
+	for i, point := range w.RunningProducts {
+		products[i] = point.Compress()
+	}
+	return merkleize([][32]byte{
+		bytesListRoot(products, MaxContributions),
+		bytesListRoot(w.compressedPubkeys(), MaxContributions),
+		bytesListRoot(w.compressedSignatures(), MaxContributions),
+	}, 3), nil
+}
+
+func (w *Witness) compressedPubkeys() [][]byte {
+	pubkeys := make([][]byte, len(w.PotPubkeys))
+	for i := range w.PotPubkeys {
+		pubkeys[i] = w.PotPubkeys[i].Compress()
+	}
+	return pubkeys
+}
+
+func (w *Witness) compressedSignatures() [][]byte {
+	signatures := w.paddedSignatures()
+	compressed := make([][]byte, len(signatures))
+	for i, sig := range signatures {
+		if sig == nil {
+			compressed[i] = infinitySignature
+			continue
+		}
+		compressed[i] = sig.Compress()
+	}
+	return compressed
+}
+
+func (t *Transcript) MarshalSSZ() ([]byte, error) {
+	pot, err := t.PowersOfTau.MarshalSSZ()
+	if err != nil {
+		return nil, err
+	}
+	witness, err := t.Witness.MarshalSSZ()
+	if err != nil {
+		return nil, err
+	}
+	fixed := make([]byte, 16)
+	binary.LittleEndian.PutUint64(fixed, uint64(t.NumG1Powers))
+	binary.LittleEndian.PutUint64(fixed[8:], uint64(t.NumG2Powers))
+	return marshalContainer(fixed, pot, witness), nil
+}
+
+func (t *Transcript) UnmarshalSSZ(b []byte) error {
+	fields, err := unmarshalContainer(b, 16, 2)
+	if err != nil {
+		return err
+	}
+	t.NumG1Powers = int(binary.LittleEndian.Uint64(b))
+	t.NumG2Powers = int(binary.LittleEndian.Uint64(b[8:]))
+	if t.NumG1Powers > MaxG1Powers || t.NumG2Powers > MaxG2Powers {
+		return errors.New("too many powers")
+	}
+	if err := t.PowersOfTau.UnmarshalSSZ(fields[0]); err != nil {
+		return err
+	}
+	t.Witness = new(Witness)
+	return t.Witness.UnmarshalSSZ(fields[1])
+}
+
+func (t *Transcript) HashTreeRoot() ([32]byte, error) {
+	pot, err := t.PowersOfTau.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	witness, err := t.Witness.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return merkleize([][32]byte{uint64Root(uint64(t.NumG1Powers)), uint64Root(uint64(t.NumG2Powers)), pot, witness}, 4), nil
+}
+
+func (c *Ceremony) MarshalSSZ() ([]byte, error) {
+	if len(c.Transcripts) > MaxTranscripts {
+		return nil, errors.New("too many transcripts")
+	}
+	transcripts := make([][]byte, len(c.Transcripts))
+	for i, t := range c.Transcripts {
+		var err error
+		if transcripts[i], err = t.MarshalSSZ(); err != nil {
+			return nil, err
+		}
+	}
+	return marshalContainer(nil, marshalContainer(nil, transcripts...)), nil
+}
+
+func (c *Ceremony) UnmarshalSSZ(b []byte) error {
+	fields, err := unmarshalContainer(b, 0, 1)
+	if err != nil {
+		return err
+	}
+	list := fields[0]
+	if len(list) == 0 {
+		c.Transcripts = []*Transcript{}
+		return nil
+	}
+	if len(list) < offsetSize {
+		return errors.New("invalid transcript list")
+	}
+	// The first offset tells the number of transcripts
+	count := int(binary.LittleEndian.Uint32(list)) / offsetSize
+	if count == 0 || count > MaxTranscripts {
+		return errors.New("invalid number of transcripts")
+	}
+	transcripts, err := unmarshalContainer(list, 0, count)
+	if err != nil {
+		return err
+	}
+	c.Transcripts = make([]*Transcript, count)
+	for i, b := range transcripts {
+		c.Transcripts[i] = new(Transcript)
+		if err := c.Transcripts[i].UnmarshalSSZ(b); err != nil {
+			return fmt.Errorf("transcript %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the ceremony, it identifies a state of the ceremony.
+func (c *Ceremony) HashTreeRoot() ([32]byte, error) {
+	if len(c.Transcripts) > MaxTranscripts {
+		return [32]byte{}, errors.New("too many transcripts")
+	}
+	roots := make([][32]byte, len(c.Transcripts))
+	for i, t := range c.Transcripts {
+		var err error
+		if roots[i], err = t.HashTreeRoot(); err != nil {
+			return [32]byte{}, err
+		}
+	}
+	return merkleize([][32]byte{mixInLength(merkleize(roots, MaxTranscripts), len(roots))}, 1), nil
+}
+
+// Digest returns a keccak256 hash over the compressed points of the ceremony. It is simpler to
+// reproduce than the hash tree root: for every transcript, each list of points is hashed as its
+// length as 8 byte big endian followed by the compressed points, in the order G1 powers, G2 powers,
+// running products, pot pubkeys and BLS signatures.
+func (c *Ceremony) Digest() [32]byte {
+	var data [][]byte
+	for _, t := range c.Transcripts {
+		g1 := make([][]byte, len(t.PowersOfTau.G1Powers))
+		for i, point := range t.PowersOfTau.G1Powers {
+			g1[i] = point.Compress()
+		}
+		g2 := make([][]byte, len(t.PowersOfTau.G2Powers))
+		for i, point := range t.PowersOfTau.G2Powers {
+			g2[i] = point.Compress()
+		}
+		products := make([][]byte, len(t.Witness.RunningProducts))
+		for i, point := range t.Witness.RunningProducts {
+			products[i] = point.Compress()
+		}
+		for _, points := range [][][]byte{g1, g2, products, t.Witness.compressedPubkeys(), t.Witness.compressedSignatures()} {
+			length := make([]byte, 8)
+			binary.BigEndian.PutUint64(length, uint64(len(points)))
+			data = append(data, length)
+			data = append(data, points...)
+		}
+	}
+	var digest [32]byte
+	copy(digest[:], crypto.Keccak256(data...))
+	return digest
+}
+
+// marshalContainer encodes a container with the fixed size fields in fixed followed by variable size fields.
+func marshalContainer(fixed []byte, variable ...[]byte) []byte {
+	offset := len(fixed) + offsetSize*len(variable)
+	out := append([]byte{}, fixed...)
+	for _, v := range variable {
+		out = append(out, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(out[len(out)-offsetSize:], uint32(offset))
+		offset += len(v)
+	}
+	for _, v := range variable {
+		out = append(out, v...)
+	}
+	return out
+}
+
+// unmarshalContainer splits b into the variable size fields of a container, the fixed size fields take fixed bytes.
+func unmarshalContainer(b []byte, fixed, count int) ([][]byte, error) {
+	start := fixed + offsetSize*count
+	if len(b) < start {
+		return nil, errors.New("ssz: container too short")
+	}
+	offsets := make([]int, count+1)
+	for i := 0; i < count; i++ {
+		offsets[i] = int(binary.LittleEndian.Uint32(b[fixed+offsetSize*i:]))
+	}
+	offsets[count] = len(b)
+	if offsets[0] != start {
+		return nil, errors.New("ssz: invalid first offset")
+	}
+	fields := make([][]byte, count)
+	for i := range fields {
+		if offsets[i] > offsets[i+1] {
+			return nil, errors.New("ssz: offsets out of order")
+		}
+		fields[i] = b[offsets[i]:offsets[i+1]]
+	}
+	return fields, nil
+}
+
+// split cuts a list of fixed size elements.
+func split(b []byte, size, limit int) ([][]byte, error) {
+	if len(b)%size != 0 || len(b)/size > limit {
+		return nil, errors.New("ssz: invalid list length")
+	}
+	elements := make([][]byte, len(b)/size)
+	for i := range elements {
+		elements[i] = b[i*size : (i+1)*size]
+	}
+	return elements, nil
+}
+
+func concat(elements [][]byte) []byte {
+	var out []byte
+	for _, e := range elements {
+		out = append(out, e...)
+	}
+	return out
+}
+
+func marshalP1s(points []*blst.P1, limit int) ([]byte, error) {
+	if len(points) > limit {
+		return nil, errors.New("ssz: list too long")
+	}
+	out := make([]byte, 0, len(points)*g1Size)
+	for _, point := range points {
+		out = append(out, point.Compress()...)
+	}
+	return out, nil
+}
+
+func marshalP2s(points []*blst.P2, limit int) ([]byte, error) {
+	if len(points) > limit {
+		return nil, errors.New("ssz: list too long")
+	}
+	out := make([]byte, 0, len(points)*g2Size)
+	for _, point := range points {
+		out = append(out, point.Compress()...)
+	}
+	return out, nil
+}
+
+func unmarshalP1s(b []byte, limit int) ([]*blst.P1, error) {
+	compressed, err := split(b, g1Size, limit)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*blst.P1, len(compressed))
+	for i, affine := range new(blst.P1Affine).BatchUncompress(compressed) {
+		if affine == nil {
+			return nil, errors.New("invalid G1 point")
+		}
+		points[i] = new(blst.P1)
+		points[i].FromAffine(affine)
+	}
+	return points, nil
+}
+
+func unmarshalP2s(b []byte, limit int) ([]*blst.P2, error) {
+	compressed, err := split(b, g2Size, limit)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*blst.P2, len(compressed))
+	for i, affine := range new(blst.P2Affine).BatchUncompress(compressed) {
+		if affine == nil {
+			return nil, errors.New("invalid G2 point")
+		}
+		points[i] = new(blst.P2)
+		points[i].FromAffine(affine)
+	}
+	return points, nil
+}
+
+// zeroHashes[i] is the root of a tree of depth i with only zero chunks
+var zeroHashes = func() [][32]byte {
+	hashes := make([][32]byte, 64)
+	for i := 1; i < len(hashes); i++ {
+		hashes[i] = hashPair(hashes[i-1], hashes[i-1])
+	}
+	return hashes
+}()
+
+func hashPair(a, b [32]byte) [32]byte {
+	return sha256.Sum256(append(a[:], b[:]...))
+}
+
+// merkleize returns the root of the chunks padded with zero chunks to the next power of two of limit.
+func merkleize(chunks [][32]byte, limit int) [32]byte {
+	depth := 0
+	for 1<<depth < limit {
+		depth++
+	}
+	layer := chunks
+	for d := 0; d < depth; d++ {
+		next := make([][32]byte, (len(layer)+1)/2)
+		for i := range next {
+			right := zeroHashes[d]
+			if 2*i+1 < len(layer) {
+				right = layer[2*i+1]
+			}
+			next[i] = hashPair(layer[2*i], right)
+		}
+		layer = next
+	}
+	if len(layer) == 0 {
+		return zeroHashes[depth]
+	}
+	return layer[0]
+}
+
+func mixInLength(root [32]byte, length int) [32]byte {
+	var chunk [32]byte
+	binary.LittleEndian.PutUint64(chunk[:], uint64(length))
+	return hashPair(root, chunk)
+}
+
+func uint64Root(v uint64) [32]byte {
+	var chunk [32]byte
+	binary.LittleEndian.PutUint64(chunk[:], v)
+	return chunk
+}
+
+// bytesRoot returns the root of a fixed size byte vector.
+func bytesRoot(b []byte) [32]byte {
+	chunks := make([][32]byte, (len(b)+31)/32)
+	for i := range chunks {
+		copy(chunks[i][:], b[i*32:])
+	}
+	return merkleize(chunks, len(chunks))
+}
+
+// bytesListRoot returns the root of a list of fixed size byte vectors.
+func bytesListRoot(elements [][]byte, limit int) [32]byte {
+	roots := make([][32]byte, len(elements))
+	for i, e := range elements {
+		roots[i] = bytesRoot(e)
+	}
+	return mixInLength(merkleize(roots, limit), len(elements))
+}
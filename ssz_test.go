@@ -0,0 +1,103 @@
+package towersofpau
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+const (
+	goldenSSZHash      = "233348fb90d667320716edb7f352b5f37b7bc3c55513ea1d3384ca1a3f827a5a"
+	goldenHashTreeRoot = "19b8b3e886fb26773d5e0b7fc81a319d4a67460550f618686ae03739e28441a2"
+	goldenDigest       = "f825abb104e0506a044cd0b602098c0f79deae06181ded6f6d3222ba1ba9ed2b"
+)
+
+// goldenCeremony is newTestCeremony with a signed contribution to the first transcript and an
+// unsigned one to the second, made with fixed secrets.
+func goldenCeremony(t *testing.T) *Ceremony {
+	ceremony := newTestCeremony()
+	for i, transcript := range ceremony.Transcripts {
+		secret := make([]byte, 32)
+		secret[31] = byte(42 + i)
+		if err := UpdatePowersOfTau(transcript, secret); err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateWitness(transcript, secret); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := SignIdentity(transcript, secret, "eth|0x0123456789abcdef0123456789abcdef01234567"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return ceremony
+}
+
+func TestSSZRoundTrip(t *testing.T) {
+	ceremony := goldenCeremony(t)
+	encoded, err := ceremony.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(Ceremony)
+	if err := decoded.UnmarshalSSZ(encoded); err != nil {
+		t.Fatal(err)
+	}
+	expected, actual := new(bytes.Buffer), new(bytes.Buffer)
+	if err := Serialize(expected, ceremony); err != nil {
+		t.Fatal(err)
+	}
+	if err := Serialize(actual, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+		t.Fatal("ceremony changed in the round trip")
+	}
+	if decoded.Transcripts[1].Witness.BlsSignatures[1] != nil {
+		t.Fatal("missing signature decoded as a signature")
+	}
+	if err := decoded.UnmarshalSSZ(encoded[:len(encoded)-1]); err == nil {
+		t.Fatal("truncated ceremony accepted")
+	}
+}
+
+// The golden values were computed with an independent implementation of SSZ and keccak256
+// from the JSON serialization of goldenCeremony.
+func TestCeremonyHashes(t *testing.T) {
+	ceremony := goldenCeremony(t)
+	encoded, err := ceremony.MarshalSSZ()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sszHash := sha256Hex(encoded)
+	if sszHash != goldenSSZHash {
+		t.Fatalf("unexpected ssz encoding with sha256 %v", sszHash)
+	}
+	root, err := ceremony.HashTreeRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(root[:]) != goldenHashTreeRoot {
+		t.Fatalf("unexpected hash tree root %x", root)
+	}
+	digest := ceremony.Digest()
+	if hex.EncodeToString(digest[:]) != goldenDigest {
+		t.Fatalf("unexpected digest %x", digest)
+	}
+
+	// Every contribution changes both hashes
+	ceremony.Transcripts[1].Witness.BlsSignatures[1] = ceremony.Transcripts[0].Witness.BlsSignatures[1]
+	if changed, _ := ceremony.HashTreeRoot(); changed == root {
+		t.Fatal("hash tree root does not cover the signatures")
+	}
+	if ceremony.Digest() == digest {
+		t.Fatal("digest does not cover the signatures")
+	}
+}
+
+func sha256Hex(b []byte) string {
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}